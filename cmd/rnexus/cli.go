@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/config"
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+// newFlagSet returns a flag set for an offline subcommand with the -c
+// config flag every subcommand takes
+func newFlagSet(name, usage string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	configPath := fs.String("c", "./config.yaml", "Path to configuration file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rnexus %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs, configPath
+}
+
+// loadConfig loads the configuration or exits
+func loadConfig(path string) *config.Config {
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	return cfg
+}
+
+// openStore opens the configured storage backend or exits. With bolt this
+// waits for the database lock, so it fails while the bot is running.
+func openStore(cfg *config.Config) storage.Store {
+	store, err := storage.Open(cfg.Storage, cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open storage (is the bot running?): %v", err)
+	}
+	return store
+}
+
+// parseTime reads a time given as a date (2006-01-02), an RFC 3339 time,
+// or an age such as 12h or 7d. The zero time is returned for "".
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use 2006-01-02, an RFC 3339 time, or an age like 12h or 7d)", s)
+}
+
+// usageExit prints usage lines and exits with status 2
+func usageExit(lines ...string) {
+	for _, line := range lines {
+		fmt.Fprintf(os.Stderr, "Usage: rnexus %s\n", line)
+	}
+	os.Exit(2)
+}
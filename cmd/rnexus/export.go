@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+// runExport renders the routing map's intended topology, or the live one
+// saved by the last LINKS, without connecting to IRC
+func runExport(args []string) {
+	fs, configPath := newFlagSet("export", "export [-c config] [-f format] [-o file] map|live")
+	format := fs.String("f", routing.FormatDOT, "Output format: dot, json or mermaid")
+	output := fs.String("o", "", "Write to this file instead of standard output")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if _, ok := routing.ExportFormats[*format]; !ok {
+		log.Fatalf("Unknown format %q (use dot, json or mermaid)", *format)
+	}
+
+	cfg := loadConfig(*configPath)
+
+	var topo *routing.Topology
+	switch fs.Arg(0) {
+	case routing.TopologyMap:
+		rmap, err := routing.LoadMap(cfg.DataDir)
+		if err != nil {
+			log.Fatalf("Failed to load routing map: %v", err)
+		}
+		topo = rmap.Topology()
+	case routing.TopologyLive:
+		path := filepath.Join(cfg.DataDir, routing.TopologyDir, routing.TopologyLive+routing.ExportFormats[routing.FormatJSON])
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("No live topology (is export_topology enabled?): %v", err)
+		}
+		if topo, err = routing.ParseTopology(data); err != nil {
+			log.Fatalf("Failed to parse %s: %v", path, err)
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	data, err := topo.Export(*format)
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *output, err)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+// runLogs handles "rnexus logs search" and "rnexus logs export"
+func runLogs(args []string) {
+	usage := []string{
+		"logs search [-c config] [-log name] [-server name] [-since time] [-until time] [-n count] <text>",
+		"logs export [-c config] [-log name] [-since time] [-until time] [-format text|json] [-o file]",
+	}
+	if len(args) == 0 {
+		usageExit(usage...)
+	}
+	switch args[0] {
+	case "search":
+		runLogsSearch(args[1:])
+	case "export":
+		runLogsExport(args[1:])
+	default:
+		usageExit(usage...)
+	}
+}
+
+// runLogsSearch prints matching routing log entries, newest first
+func runLogsSearch(args []string) {
+	fs, configPath := newFlagSet("logs search", "logs search [-c config] [-log name] [-server name] [-since time] [-until time] [-n count] <text>")
+	category := fs.String("log", "", "Search the log of this notice filter instead of the routing log")
+	server := fs.String("server", "", "Only notices from this server")
+	since := fs.String("since", "", "Only entries at or after this time (2006-01-02, RFC 3339, or an age like 7d)")
+	until := fs.String("until", "", "Only entries before this time")
+	count := fs.Int("n", 0, "Show at most this many entries, 0 for all")
+	fs.Parse(args)
+
+	q := storage.Query{Server: *server, Text: strings.Join(fs.Args(), " "), Category: logCategory(*category), Limit: *count}
+	if q.Text == "" && q.Server == "" && q.Category == "" && *since == "" && *until == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	q.Since, q.Until = parseRange(*since, *until)
+
+	store := openStore(loadConfig(*configPath))
+	defer store.Close()
+
+	logs, err := store.Logs(q)
+	if err != nil {
+		log.Fatalf("Failed to read logs: %v", err)
+	}
+	for _, r := range logs {
+		fmt.Println(r)
+	}
+	fmt.Fprintf(os.Stderr, "%d matches\n", len(logs))
+}
+
+// runLogsExport writes routing log entries, oldest first, as logs.txt lines
+// or JSON lines
+func runLogsExport(args []string) {
+	fs, configPath := newFlagSet("logs export", "logs export [-c config] [-log name] [-since time] [-until time] [-format text|json] [-o file]")
+	category := fs.String("log", "", "Export the log of this notice filter instead of the routing log")
+	since := fs.String("since", "", "Only entries at or after this time (2006-01-02, RFC 3339, or an age like 7d)")
+	until := fs.String("until", "", "Only entries before this time")
+	format := fs.String("format", "text", "Output format: text or json (one record per line)")
+	output := fs.String("o", "", "Write to this file instead of standard output")
+	fs.Parse(args)
+
+	if fs.NArg() != 0 || (*format != "text" && *format != "json") {
+		fs.Usage()
+		os.Exit(2)
+	}
+	q := storage.Query{Category: logCategory(*category)}
+	q.Since, q.Until = parseRange(*since, *until)
+
+	store := openStore(loadConfig(*configPath))
+	defer store.Close()
+
+	logs, err := store.Logs(q)
+	if err != nil {
+		log.Fatalf("Failed to read logs: %v", err)
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Time.Before(logs[j].Time)
+	})
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	enc := json.NewEncoder(w)
+	for _, r := range logs {
+		if *format == "json" {
+			err = enc.Encode(r)
+		} else {
+			_, err = fmt.Fprintln(w, r)
+		}
+		if err != nil {
+			log.Fatalf("Failed to write logs: %v", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d entries\n", len(logs))
+}
+
+// runStats summarises the command audit log by command and by user
+func runStats(args []string) {
+	fs, configPath := newFlagSet("stats", "stats [-c config] [-user nick] [-since time] [-until time] [-n count]")
+	user := fs.String("user", "", "Only commands from this nick")
+	since := fs.String("since", "", "Only commands at or after this time (2006-01-02, RFC 3339, or an age like 7d)")
+	until := fs.String("until", "", "Only commands before this time")
+	top := fs.Int("n", 10, "Show this many commands and users")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	q := storage.Query{User: *user}
+	q.Since, q.Until = parseRange(*since, *until)
+
+	store := openStore(loadConfig(*configPath))
+	defer store.Close()
+
+	stats, err := store.Stats(q)
+	if err != nil {
+		log.Fatalf("Failed to read stats: %v", err)
+	}
+	if len(stats) == 0 {
+		fmt.Println("No commands recorded")
+		return
+	}
+
+	commands := make(map[string]int)
+	users := make(map[string]int)
+	for _, r := range stats {
+		name := r.Command
+		if fields := strings.Fields(name); len(fields) > 0 {
+			name = strings.ToLower(fields[0])
+		}
+		commands[name]++
+		users[r.User]++
+	}
+
+	// Stats are newest first
+	fmt.Printf("%d commands from %s to %s\n", len(stats),
+		stats[len(stats)-1].Time.UTC().Format("2006-01-02 15:04"), stats[0].Time.UTC().Format("2006-01-02 15:04"))
+	printCounts("Commands", commands, *top)
+	printCounts("Users", users, *top)
+}
+
+// printCounts prints the n largest counts, most used first
+func printCounts(title string, counts map[string]int, n int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if n > 0 && len(keys) > n {
+		keys = keys[:n]
+	}
+
+	fmt.Printf("%s:\n", title)
+	for _, k := range keys {
+		fmt.Printf("  %6d  %s\n", counts[k], k)
+	}
+}
+
+// parseRange parses -since and -until or exits
+func parseRange(since, until string) (from, to time.Time) {
+	from, err := parseTime(since)
+	if err != nil {
+		log.Fatalf("Bad -since: %v", err)
+	}
+	if to, err = parseTime(until); err != nil {
+		log.Fatalf("Bad -until: %v", err)
+	}
+	return from, to
+}
+
+// logCategory checks a -log name, mapping "routing" to the routing log
+func logCategory(name string) string {
+	name = strings.ToLower(name)
+	if name == "routing" {
+		return ""
+	}
+	if name != "" && !storage.ValidCategory(name) {
+		log.Fatalf("Invalid log name %q", name)
+	}
+	return name
+}
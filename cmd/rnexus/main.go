@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/dalnet/rnexus/internal/config"
 	"github.com/dalnet/rnexus/internal/irc"
+	"github.com/dalnet/rnexus/internal/storage"
+	"github.com/dalnet/rnexus/internal/web"
 )
 
 // Version information - set at build time via ldflags
@@ -21,12 +24,29 @@ var (
 	gitCommit = "unknown"
 )
 
+// subcommands run without connecting to IRC
+var subcommands = map[string]func(args []string){
+	"export": runExport,
+	"map":    runMap,
+	"logs":   runLogs,
+	"stats":  runStats,
+}
+
 func main() {
+	// Offline subcommands
+	if len(os.Args) > 1 {
+		if sub, ok := subcommands[os.Args[1]]; ok {
+			sub(os.Args[2:])
+			return
+		}
+	}
+
 	// Command line flags
 	foreground := flag.Bool("x", false, "Run in foreground (don't daemonize)")
 	configPath := flag.String("c", "./config.yaml", "Path to configuration file")
 	showVersion := flag.Bool("v", false, "Show version information and exit")
 	showVersionLong := flag.Bool("version", false, "Show version information and exit")
+	importFiles := flag.Bool("import", false, "Import the text file logs, stats and MOTD into an empty database and exit")
 	flag.Parse()
 
 	// Show version and exit
@@ -37,6 +57,12 @@ func main() {
 		os.Exit(0)
 	}
 
+	// One-shot import from the text files
+	if *importFiles {
+		runImport(*configPath)
+		return
+	}
+
 	// Set version info in irc package
 	irc.Version = version
 	irc.BuildDate = buildDate
@@ -118,6 +144,29 @@ func writePIDFile() error {
 	return os.WriteFile("pid.txt", []byte(fmt.Sprintf("%d\n", pid)), 0644)
 }
 
+func runImport(configPath string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if cfg.Storage != storage.BackendBolt {
+		log.Fatalf("Import needs storage: %s in the configuration (currently %q)", storage.BackendBolt, cfg.Storage)
+	}
+
+	store, err := storage.OpenBolt(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	logs, stats, err := storage.Import(store, cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Import failed after %d logs and %d stats: %v", logs, stats, err)
+	}
+	fmt.Printf("Imported %d logs and %d stats from %s\n", logs, stats, cfg.DataDir)
+}
+
 func run(configPath string) {
 	// Make config path absolute
 	if !filepath.IsAbs(configPath) {
@@ -142,15 +191,36 @@ func run(configPath string) {
 		log.Fatalf("Failed to create IRC client: %v", err)
 	}
 
+	// Status page, JSON API and metrics
+	var server *web.Server
+	if cfg.HTTPListen != "" {
+		server = web.New(cfg, client)
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Status page stopped: %v", err)
+			}
+		}()
+	}
+
+	// quit stops the status page and disconnects
+	quit := func(message string) {
+		if server != nil {
+			if err := server.Close(); err != nil {
+				log.Printf("Error stopping the status page: %v", err)
+			}
+		}
+		client.Quit(message)
+	}
+
 	// Set up shutdown handler
 	client.OnShutdown = func() {
-		client.Quit("Shutdown requested")
+		quit("Shutdown requested")
 		os.Exit(0)
 	}
 
 	// Set up restart handler
 	client.OnRestart = func() {
-		client.Quit("Restarting")
+		quit("Restarting")
 
 		// Re-exec ourselves
 		args := os.Args
@@ -175,16 +245,10 @@ func run(configPath string) {
 	go func() {
 		sig := <-sigChan
 		log.Printf("Received signal %v, shutting down...", sig)
-		client.Quit("Received shutdown signal")
+		quit("Received shutdown signal")
 		os.Exit(0)
 	}()
 
-	// Connect and run
-	log.Printf("Connecting to %s:%d...", cfg.Server, cfg.Port)
-	if err := client.Connect(); err != nil {
-		log.Fatalf("Failed to connect: %v", err)
-	}
-
-	log.Println("Connected, entering main loop...")
-	client.Loop()
+	// Connect and run, reconnecting until shut down
+	client.Run()
 }
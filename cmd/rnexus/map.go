@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+// runMap handles "rnexus map validate" and "rnexus map show"
+func runMap(args []string) {
+	if len(args) == 0 {
+		usageExit("map validate [-c config] [file]", "map show [-c config] <server>")
+	}
+	switch args[0] {
+	case "validate":
+		runMapValidate(args[1:])
+	case "show":
+		runMapShow(args[1:])
+	default:
+		usageExit("map validate [-c config] [file]", "map show [-c config] <server>")
+	}
+}
+
+// runMapValidate checks rmap.txt, or the given file, and exits with status
+// 1 if it has errors
+func runMapValidate(args []string) {
+	fs, configPath := newFlagSet("map validate", "map validate [-c config] [file]")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	path := fs.Arg(0)
+	if path == "" {
+		path = filepath.Join(loadConfig(*configPath).DataDir, "rmap.txt")
+	}
+	if _, err := os.Stat(path); err != nil {
+		log.Fatalf("Cannot read routing map: %v", err)
+	}
+
+	rmap, err := routing.LoadMapFile(path)
+	if err != nil {
+		log.Fatalf("Failed to load %s: %v", path, err)
+	}
+
+	problems := rmap.Validate()
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", path, p)
+	}
+	fmt.Printf("%s: %d servers, %d problems\n", path, len(rmap.ServerList), len(problems))
+	if routing.HasErrors(problems) {
+		os.Exit(1)
+	}
+}
+
+// runMapShow prints the map entries for servers matching a name
+func runMapShow(args []string) {
+	fs, configPath := newFlagSet("map show", "map show [-c config] <server>")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	rmap, err := routing.LoadMap(loadConfig(*configPath).DataDir)
+	if err != nil {
+		log.Fatalf("Failed to load routing map: %v", err)
+	}
+
+	server := fs.Arg(0)
+	if idx := strings.Index(server, "."); idx > 0 {
+		server = server[:idx]
+	}
+	matches := rmap.FindServer(server)
+	if len(matches) == 0 {
+		fmt.Fprintf(os.Stderr, "No such server found\n")
+		os.Exit(1)
+	}
+
+	ranks := []string{"primary", "secondary", "tertiary"}
+	for _, name := range matches {
+		entry := rmap.Entry(name)
+		if entry == nil {
+			continue
+		}
+		fmt.Printf("%s (line %d)\n", entry, entry.Line)
+		fmt.Printf("    role: %s", entry.Role)
+		if entry.Tier > 0 {
+			fmt.Printf(", tier %d", entry.Tier)
+		}
+		fmt.Println()
+		for i, hub := range entry.Hubs {
+			rank := fmt.Sprintf("#%d", i+1)
+			if i < len(ranks) {
+				rank = ranks[i]
+			}
+			fmt.Printf("    %s: %s\n", rank, hub)
+		}
+
+		var downlinks []string
+		for _, other := range rmap.ServerList {
+			for _, hub := range rmap.Servers[other] {
+				if strings.EqualFold(hub, entry.Name) {
+					downlinks = append(downlinks, other)
+					break
+				}
+			}
+		}
+		if len(downlinks) > 0 {
+			fmt.Printf("    assigned to it: %s\n", strings.Join(downlinks, " "))
+		}
+	}
+}
@@ -4,5 +4,9 @@ go 1.21
 
 require (
 	github.com/ergochat/irc-go v0.4.0
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/crypto v0.23.0
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.20.0 // indirect
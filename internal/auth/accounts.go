@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role is an admin account's privilege level
+type Role string
+
+const (
+	RoleViewer       Role = "viewer"
+	RoleRoutingAdmin Role = "routing-admin"
+	RoleBotOwner     Role = "bot-owner"
+)
+
+// roleRanks orders roles from least to most privileged
+var roleRanks = map[Role]int{
+	RoleViewer:       1,
+	RoleRoutingAdmin: 2,
+	RoleBotOwner:     3,
+}
+
+// Roles lists the valid roles in order of privilege
+var Roles = []Role{RoleViewer, RoleRoutingAdmin, RoleBotOwner}
+
+// ParseRole validates a role name
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(s))
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return role, nil
+}
+
+// Allows reports whether r grants at least the privileges of required
+func (r Role) Allows(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+var (
+	ErrNoSuchAccount = errors.New("no such account")
+	ErrAccountExists = errors.New("account already exists")
+	ErrInvalidName   = errors.New("account names may not contain spaces or colons")
+)
+
+// Account is a named admin login
+type Account struct {
+	Name string
+	Role Role
+	Hash string // bcrypt hash of the password
+}
+
+// Accounts is the set of admin accounts stored in accounts.txt in the data
+// directory, one "name:role:hash" line per account
+type Accounts struct {
+	path string
+
+	mu       sync.Mutex
+	accounts map[string]*Account // Keyed by lowercased name
+}
+
+// LoadAccounts reads the accounts file from dataDir. A missing file is an
+// empty set of accounts.
+func LoadAccounts(dataDir string) (*Accounts, error) {
+	a := &Accounts{
+		path:     filepath.Join(dataDir, "accounts.txt"),
+		accounts: make(map[string]*Account),
+	}
+
+	file, err := os.Open(a.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%s line %d: expected name:role:hash", a.path, lineNo)
+		}
+		role, err := ParseRole(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", a.path, lineNo, err)
+		}
+		a.accounts[strings.ToLower(parts[0])] = &Account{Name: parts[0], Role: role, Hash: parts[2]}
+	}
+
+	return a, scanner.Err()
+}
+
+// Len returns the number of accounts
+func (a *Accounts) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.accounts)
+}
+
+// Get returns a copy of the named account
+func (a *Accounts) Get(name string) (Account, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	acct, ok := a.accounts[strings.ToLower(name)]
+	if !ok {
+		return Account{}, false
+	}
+	return *acct, true
+}
+
+// dummyHash is compared against for unknown accounts, so Verify takes as
+// long whether or not the account exists
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("no such account"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Verify checks a password and returns the account it unlocks
+func (a *Accounts) Verify(name, password string) (Account, bool) {
+	acct, ok := a.Get(name)
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return Account{}, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(acct.Hash), []byte(password)) != nil {
+		return Account{}, false
+	}
+	return acct, true
+}
+
+// Add creates a new account
+func (a *Accounts) Add(name string, role Role, password string) error {
+	if name == "" || strings.ContainsAny(name, ": \t") {
+		return ErrInvalidName
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := strings.ToLower(name)
+	if _, exists := a.accounts[key]; exists {
+		return ErrAccountExists
+	}
+	next := a.clone()
+	next[key] = &Account{Name: name, Role: role, Hash: string(hash)}
+	return a.commit(next)
+}
+
+// Remove deletes an account
+func (a *Accounts) Remove(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := strings.ToLower(name)
+	if _, exists := a.accounts[key]; !exists {
+		return ErrNoSuchAccount
+	}
+	next := a.clone()
+	delete(next, key)
+	return a.commit(next)
+}
+
+// SetPassword replaces an account's password
+func (a *Accounts) SetPassword(name, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := strings.ToLower(name)
+	if _, exists := a.accounts[key]; !exists {
+		return ErrNoSuchAccount
+	}
+	next := a.clone()
+	next[key].Hash = string(hash)
+	return a.commit(next)
+}
+
+// SetRole changes an account's role
+func (a *Accounts) SetRole(name string, role Role) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := strings.ToLower(name)
+	if _, exists := a.accounts[key]; !exists {
+		return ErrNoSuchAccount
+	}
+	next := a.clone()
+	next[key].Role = role
+	return a.commit(next)
+}
+
+// List returns all accounts sorted by name
+func (a *Accounts) List() []Account {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	list := make([]Account, 0, len(a.accounts))
+	for _, acct := range a.accounts {
+		list = append(list, *acct)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	return list
+}
+
+// clone copies the accounts for a change; the caller holds a.mu
+func (a *Accounts) clone() map[string]*Account {
+	next := make(map[string]*Account, len(a.accounts))
+	for key, acct := range a.accounts {
+		copied := *acct
+		next[key] = &copied
+	}
+	return next
+}
+
+// commit saves the changed accounts and only then puts them in use, so a
+// failed write leaves things as they are on disk; the caller holds a.mu
+func (a *Accounts) commit(next map[string]*Account) error {
+	if err := a.save(next); err != nil {
+		return err
+	}
+	a.accounts = next
+	return nil
+}
+
+// save writes accounts to the accounts file
+func (a *Accounts) save(accounts map[string]*Account) error {
+	names := make([]string, 0, len(accounts))
+	for key := range accounts {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, key := range names {
+		acct := accounts[key]
+		fmt.Fprintf(&b, "%s:%s:%s\n", acct.Name, acct.Role, acct.Hash)
+	}
+
+	// Hashes only, but still keep the file private
+	return os.WriteFile(a.path, []byte(b.String()), 0600)
+}
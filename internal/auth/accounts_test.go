@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAccountsRoundTrip(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rnexus-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	accounts, err := LoadAccounts(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadAccounts failed: %v", err)
+	}
+	if accounts.Len() != 0 {
+		t.Errorf("Expected no accounts, got %d", accounts.Len())
+	}
+
+	if err := accounts.Add("Alice", RoleBotOwner, "secret"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := accounts.Add("alice", RoleViewer, "other"); err != ErrAccountExists {
+		t.Errorf("Expected ErrAccountExists, got %v", err)
+	}
+	if err := accounts.Add("bob:x", RoleViewer, "other"); err != ErrInvalidName {
+		t.Errorf("Expected ErrInvalidName, got %v", err)
+	}
+
+	// The password is not stored in plain text
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "accounts.txt"))
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("Password stored in plain text: %q", string(data))
+	}
+
+	// Reload from disk
+	accounts, err = LoadAccounts(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadAccounts failed: %v", err)
+	}
+
+	acct, ok := accounts.Verify("ALICE", "secret")
+	if !ok || acct.Name != "Alice" || acct.Role != RoleBotOwner {
+		t.Errorf("Verify failed: %+v %v", acct, ok)
+	}
+	if _, ok := accounts.Verify("alice", "wrong"); ok {
+		t.Errorf("Verify accepted a wrong password")
+	}
+
+	if err := accounts.SetPassword("alice", "newsecret"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	if _, ok := accounts.Verify("alice", "newsecret"); !ok {
+		t.Errorf("Verify failed after password change")
+	}
+
+	if err := accounts.Remove("alice"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := accounts.Remove("alice"); err != ErrNoSuchAccount {
+		t.Errorf("Expected ErrNoSuchAccount, got %v", err)
+	}
+}
+
+func TestAccountsFailedSave(t *testing.T) {
+	dir := t.TempDir()
+	accounts, err := LoadAccounts(dir)
+	if err != nil {
+		t.Fatalf("LoadAccounts failed: %v", err)
+	}
+	if err := accounts.Add("alice", RoleViewer, "secret"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// Make the file unwritable by putting a directory in its place
+	path := filepath.Join(dir, "accounts.txt")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := accounts.Add("bob", RoleViewer, "secret"); err == nil {
+		t.Fatal("Expected Add to fail")
+	}
+	if err := accounts.SetRole("alice", RoleBotOwner); err == nil {
+		t.Fatal("Expected SetRole to fail")
+	}
+	if err := accounts.Remove("alice"); err == nil {
+		t.Fatal("Expected Remove to fail")
+	}
+
+	// Nothing that failed to save is in use
+	if _, ok := accounts.Get("bob"); ok {
+		t.Error("Unsaved account bob was added")
+	}
+	if acct, ok := accounts.Get("alice"); !ok || acct.Role != RoleViewer {
+		t.Errorf("Unsaved changes to alice were kept: %+v, %v", acct, ok)
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	if !RoleBotOwner.Allows(RoleRoutingAdmin) {
+		t.Errorf("bot-owner should allow routing-admin")
+	}
+	if RoleViewer.Allows(RoleRoutingAdmin) {
+		t.Errorf("viewer should not allow routing-admin")
+	}
+	if _, err := ParseRole("superuser"); err == nil {
+		t.Errorf("ParseRole accepted an unknown role")
+	}
+}
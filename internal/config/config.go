@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -21,6 +23,108 @@ type Config struct {
 	OperPass   string `yaml:"oper_pass"`
 	AdminPass  string `yaml:"admin_pass"`
 	DataDir    string `yaml:"data_dir"`
+
+	// Servers lists "host:port" servers to connect to, tried in order when
+	// one keeps failing. Server and Port are used when it is empty.
+	Servers []string `yaml:"servers"`
+
+	// TLS settings. TLSCA is a PEM bundle to verify the server against
+	// instead of the system roots; TLSCert/TLSKey is a client certificate
+	// for CertFP. TLSServerName overrides the name checked against the
+	// server certificate.
+	TLS           bool   `yaml:"tls"`
+	TLSCA         string `yaml:"tls_ca"`
+	TLSCert       string `yaml:"tls_cert"`
+	TLSKey        string `yaml:"tls_key"`
+	TLSServerName string `yaml:"tls_server_name"`
+	TLSInsecure   bool   `yaml:"tls_insecure"`
+
+	// SASLMech is "PLAIN" or "EXTERNAL" (with TLSCert), empty to disable
+	SASLMech     string `yaml:"sasl_mech"`
+	SASLLogin    string `yaml:"sasl_login"`
+	SASLPassword string `yaml:"sasl_password"`
+
+	// AuthMode selects how admins log in: "password" (accounts.txt, the
+	// default) or "nickserv" (identified nick on NickServAdmins)
+	AuthMode string `yaml:"auth_mode"`
+	// NickServAdmins maps registered nicks to their admin role
+	NickServAdmins map[string]string `yaml:"nickserv_admins"`
+	// NickServAutoLogin logs allowed admins in on their first command
+	NickServAutoLogin bool `yaml:"nickserv_autologin"`
+
+	// Storage selects the backend for logs, stats and the MOTD:
+	// "files" (text files, the default) or "bolt" (embedded database)
+	Storage string `yaml:"storage"`
+
+	// ReportChannel is joined (with ReportKey) to announce routing events.
+	// ReportEvents selects the event types, ReportRate caps lines a minute.
+	ReportChannel string   `yaml:"report_channel"`
+	ReportKey     string   `yaml:"report_key"`
+	ReportEvents  []string `yaml:"report_events"`
+	ReportRate    int      `yaml:"report_rate"`
+
+	// SendBurst and SendRate set flood control: lines sent back to back,
+	// then lines per second. 0 uses the defaults (5 and 2).
+	SendBurst int     `yaml:"send_burst"`
+	SendRate  float64 `yaml:"send_rate"`
+
+	// ConnectPort is the server port shown in !fixplan CONNECT commands
+	ConnectPort int `yaml:"connect_port"`
+
+	// PageSize is how many lines of a long reply are sent before waiting
+	// for !more. 0 uses the default (15).
+	PageSize int `yaml:"page_size"`
+
+	// ExportTopology writes the live and intended topologies as DOT, JSON
+	// and Mermaid to data_dir/topology after each LINKS
+	ExportTopology bool `yaml:"export_topology"`
+
+	// HTTPListen is the address for the status page, JSON API and metrics,
+	// empty to disable them.
+	// A bare port (":8080" or "8080") listens on localhost only. Setting
+	// HTTPUser and HTTPPassword (both or neither) turns on basic auth.
+	HTTPListen   string `yaml:"http_listen"`
+	HTTPUser     string `yaml:"http_user"`
+	HTTPPassword string `yaml:"http_password"`
+
+	// NoticeFilters pick out server notices to log, first match wins. The
+	// "routing" filter feeds the routing log; the default is DALnet's
+	// "*** Routing" notices from dal.net and upenn.edu servers.
+	NoticeFilters []NoticeFilter `yaml:"notice_filters"`
+
+	// Webhooks receive a POST for routing events, see Webhook
+	Webhooks []Webhook `yaml:"webhooks"`
+
+	// PollInterval is how often, in seconds, LINKS is polled to detect
+	// topology changes. 0 disables polling.
+	PollInterval int `yaml:"poll_interval"`
+}
+
+// NoticeFilter matches server notices by source and text. Notices matched
+// by a filter other than "routing" go to a separate log named after it.
+type NoticeFilter struct {
+	Name string `yaml:"name"`
+	// Sources are suffixes of the sending server's name, any server if empty
+	Sources []string `yaml:"sources"`
+	// Match is text the notice must contain
+	Match string `yaml:"match"`
+	// Regex treats Sources and Match as regular expressions
+	Regex bool `yaml:"regex"`
+	// Strip is a prefix removed from the notice before it is logged
+	Strip string `yaml:"strip"`
+}
+
+// Webhook is a URL that routing events are POSTed to
+type Webhook struct {
+	URL string `yaml:"url"`
+	// Secret signs each body with HMAC-SHA256 in X-Rnexus-Signature
+	Secret string `yaml:"secret"`
+	// Events are the event types to send, default split, link and moved
+	Events []string `yaml:"events"`
+	// Format is "json" (the default), "slack" or "discord"
+	Format string `yaml:"format"`
+	// Template is the Go template for the slack and discord message text
+	Template string `yaml:"template"`
 }
 
 // Load reads and parses a YAML configuration file
@@ -40,5 +144,45 @@ func Load(path string) (*Config, error) {
 		cfg.DataDir = "./data"
 	}
 
+	if len(cfg.ServerList()) == 0 {
+		return nil, fmt.Errorf("no servers configured")
+	}
+
+	// Basic auth with a blank user, or a user with no password, is not
+	// what anyone means
+	if (cfg.HTTPUser == "") != (cfg.HTTPPassword == "") {
+		return nil, fmt.Errorf("http_user and http_password must be set together")
+	}
+
 	return &cfg, nil
 }
+
+// ServerList returns the "host:port" addresses to connect to
+func (c *Config) ServerList() []string {
+	var servers []string
+	for _, s := range c.Servers {
+		if s = strings.TrimSpace(s); s != "" {
+			servers = append(servers, s)
+		}
+	}
+	if len(servers) == 0 && c.Server != "" {
+		servers = append(servers, fmt.Sprintf("%s:%d", c.Server, c.Port))
+	}
+	return servers
+}
+
+// HTTPAddr returns the status page listen address, with the host defaulting
+// to localhost
+func (c *Config) HTTPAddr() string {
+	addr := strings.TrimSpace(c.HTTPListen)
+	if addr == "" {
+		return ""
+	}
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	return addr
+}
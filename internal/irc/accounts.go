@@ -0,0 +1,261 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dalnet/rnexus/internal/auth"
+)
+
+// sharedAccount is the session name used when logging in with the legacy
+// admin_pass, which is only accepted while no accounts exist
+const sharedAccount = "admin_pass"
+
+// Admin authentication modes
+const (
+	authPassword = "password"
+	authNickServ = "nickserv"
+)
+
+// adminSession is a logged in admin
+type adminSession struct {
+	account string
+	role    auth.Role
+}
+
+// session returns the admin session for nick, or nil if not logged in
+func (c *Client) session(nick string) *adminSession {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.admins[nick]
+}
+
+// checkRole reports whether nick is logged in with at least the given role.
+// Otherwise it tells them why not and logs the attempt to do action.
+func (c *Client) checkRole(nick, hostmask string, role auth.Role, denied, action string) bool {
+	s := c.session(nick)
+	if s == nil {
+		c.privmsg(nick, denied)
+		c.logCommand(hostmask, fmt.Sprintf("tried to %s, but wasn't logged in", action))
+		return false
+	}
+	if !s.role.Allows(role) {
+		c.privmsg(nick, fmt.Sprintf("Sorry, that needs the %s role and your account is %s", role, s.role))
+		c.logCommand(hostmask, fmt.Sprintf("tried to %s without the %s role", action, role))
+		return false
+	}
+	return true
+}
+
+// nickServAuth reports whether admins log in by NickServ identification
+func (c *Client) nickServAuth() bool {
+	return strings.EqualFold(c.cfg.AuthMode, authNickServ)
+}
+
+// isLoginCommand reports whether message is a !login or !su command
+func isLoginCommand(message string) bool {
+	fields := strings.Fields(strings.ToLower(message))
+	return len(fields) > 0 && (fields[0] == "!login" || fields[0] == "!su")
+}
+
+// checkNickServAdmins makes sure every nickserv_admins entry has a known role
+func checkNickServAdmins(admins map[string]string) error {
+	for name, role := range admins {
+		if _, err := auth.ParseRole(role); err != nil {
+			return fmt.Errorf("nickserv_admins: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// loginNickServ starts a session for nick if the NickServ account they are
+// identified to is on the nickserv_admins allow-list. auto is set when this
+// is an automatic login rather than an explicit !login, and stays quiet if
+// the account isn't allowed.
+func (c *Client) loginNickServ(nick, hostmask, account string, auto bool) {
+	if account == "" {
+		if !auto {
+			c.privmsg(nick, "You need to be identified to NickServ to log in")
+			c.logCommand(hostmask, "INCORRECT LOGIN ATTEMPT - not identified to NickServ")
+		}
+		return
+	}
+
+	var roleName string
+	for name, r := range c.cfg.NickServAdmins {
+		if strings.EqualFold(name, account) {
+			roleName = r
+			break
+		}
+	}
+	if roleName == "" {
+		if !auto {
+			c.privmsg(nick, fmt.Sprintf("Sorry, %s is not on my list of admins", account))
+			c.logCommand(hostmask, fmt.Sprintf("INCORRECT LOGIN ATTEMPT - %s is not an admin", account))
+		}
+		return
+	}
+
+	role, err := auth.ParseRole(roleName)
+	if err != nil {
+		c.privmsg(nick, fmt.Sprintf("Sorry, %s has an invalid role in my configuration", account))
+		c.logCommand(hostmask, fmt.Sprintf("login refused for %s: %v", account, err))
+		return
+	}
+
+	c.startSession(nick, &adminSession{account: account, role: role})
+	c.privmsg(nick, fmt.Sprintf("You are identified as %s, you are now logged in as %s. Type !help for a list of admin-only commands", account, role))
+	if auto {
+		c.logCommand(hostmask, "automatic login by NickServ identification")
+	} else {
+		c.logCommand(hostmask, "successful login by NickServ identification")
+	}
+}
+
+// startSession logs nick in and watches for them signing off
+func (c *Client) startSession(nick string, s *adminSession) {
+	c.mu.Lock()
+	c.admins[nick] = s
+	c.mu.Unlock()
+
+	c.sendRaw(fmt.Sprintf("WATCH +%s", nick))
+}
+
+// endSessions logs out every nick using the given account
+func (c *Client) endSessions(account string) {
+	c.mu.Lock()
+	var nicks []string
+	for nick, s := range c.admins {
+		if strings.EqualFold(s.account, account) {
+			nicks = append(nicks, nick)
+			delete(c.admins, nick)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, nick := range nicks {
+		c.sendRaw(fmt.Sprintf("WATCH -%s", nick))
+		c.privmsg(nick, fmt.Sprintf("You have been logged out, account %s was removed", account))
+	}
+}
+
+func (c *Client) cmdPasswd(nick, hostmask, message string) {
+	s := c.session(nick)
+	if s == nil || s.account == sharedAccount || c.nickServAuth() {
+		c.privmsg(nick, "You need to be logged in to an account to change its password")
+		c.logCommand(hostmask, "tried to change password, but wasn't logged in to an account")
+		return
+	}
+
+	parts := strings.Fields(message)
+	if err := c.accounts.SetPassword(s.account, parts[1]); err != nil {
+		c.privmsg(nick, fmt.Sprintf("Error changing password: %v", err))
+		return
+	}
+
+	c.privmsg(nick, "Password changed")
+	c.logCommand(hostmask, "changed own password")
+}
+
+// passwordSpacesReply is the reply to a password given as several words, which
+// !login could never match
+const passwordSpacesReply = "Passwords can't contain spaces"
+
+func (c *Client) cmdAccount(nick, hostmask, message string) {
+	parts := strings.Fields(message)
+
+	roles := make([]string, len(auth.Roles))
+	for i, r := range auth.Roles {
+		roles[i] = string(r)
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "list":
+		accounts := c.accounts.List()
+		if len(accounts) == 0 {
+			c.privmsg(nick, "No accounts have been created")
+			return
+		}
+		for _, acct := range accounts {
+			c.privmsg(nick, fmt.Sprintf("%s (%s)", acct.Name, acct.Role))
+		}
+
+	case "add":
+		if len(parts) < 5 {
+			c.privmsg(nick, fmt.Sprintf("Usage: !account add <name> <role> <password> - roles are %s", strings.Join(roles, ", ")))
+			return
+		}
+		if len(parts) > 5 {
+			c.privmsg(nick, passwordSpacesReply)
+			return
+		}
+		role, err := auth.ParseRole(parts[3])
+		if err != nil {
+			c.privmsg(nick, fmt.Sprintf("Unknown role %s, try one of: %s", parts[3], strings.Join(roles, ", ")))
+			return
+		}
+		if err := c.accounts.Add(parts[2], role, parts[4]); err != nil {
+			c.privmsg(nick, fmt.Sprintf("Could not add account %s: %v", parts[2], err))
+			return
+		}
+		c.privmsg(nick, fmt.Sprintf("Account %s added as %s", parts[2], role))
+		c.logCommand(hostmask, fmt.Sprintf("added account %s as %s", parts[2], role))
+
+	case "del", "remove":
+		if len(parts) < 3 {
+			c.privmsg(nick, "Usage: !account del <name>")
+			return
+		}
+		if err := c.accounts.Remove(parts[2]); err != nil {
+			c.privmsg(nick, fmt.Sprintf("Could not remove account %s: %v", parts[2], err))
+			return
+		}
+		c.endSessions(parts[2])
+		c.privmsg(nick, fmt.Sprintf("Account %s removed", parts[2]))
+		c.logCommand(hostmask, fmt.Sprintf("removed account %s", parts[2]))
+
+	case "passwd":
+		if len(parts) < 4 {
+			c.privmsg(nick, "Usage: !account passwd <name> <password>")
+			return
+		}
+		if len(parts) > 4 {
+			c.privmsg(nick, passwordSpacesReply)
+			return
+		}
+		if err := c.accounts.SetPassword(parts[2], parts[3]); err != nil {
+			c.privmsg(nick, fmt.Sprintf("Could not change password for %s: %v", parts[2], err))
+			return
+		}
+		c.privmsg(nick, fmt.Sprintf("Password changed for %s", parts[2]))
+		c.logCommand(hostmask, fmt.Sprintf("changed password for account %s", parts[2]))
+
+	case "role":
+		if len(parts) < 4 {
+			c.privmsg(nick, fmt.Sprintf("Usage: !account role <name> <role> - roles are %s", strings.Join(roles, ", ")))
+			return
+		}
+		role, err := auth.ParseRole(parts[3])
+		if err != nil {
+			c.privmsg(nick, fmt.Sprintf("Unknown role %s, try one of: %s", parts[3], strings.Join(roles, ", ")))
+			return
+		}
+		if err := c.accounts.SetRole(parts[2], role); err != nil {
+			c.privmsg(nick, fmt.Sprintf("Could not change role for %s: %v", parts[2], err))
+			return
+		}
+		// Active sessions pick up the new role straight away
+		c.mu.Lock()
+		for n, s := range c.admins {
+			if strings.EqualFold(s.account, parts[2]) {
+				c.admins[n] = &adminSession{account: s.account, role: role}
+			}
+		}
+		c.mu.Unlock()
+		c.privmsg(nick, fmt.Sprintf("Account %s is now %s", parts[2], role))
+		c.logCommand(hostmask, fmt.Sprintf("changed role of account %s to %s", parts[2], role))
+
+	default:
+		c.privmsg(nick, "Usage: !account list | add <name> <role> <password> | del <name> | passwd <name> <password> | role <name> <role>")
+	}
+}
@@ -0,0 +1,12 @@
+package irc
+
+import "testing"
+
+func TestCheckNickServAdmins(t *testing.T) {
+	if err := checkNickServAdmins(map[string]string{"Alice": "bot-owner", "Bob": "Viewer"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := checkNickServAdmins(map[string]string{"Carol": "superuser"}); err == nil {
+		t.Error("Expected an unknown role to be rejected")
+	}
+}
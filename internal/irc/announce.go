@@ -0,0 +1,211 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+// Report types for LINKS snapshot findings, alongside the routing event types
+const (
+	reportMisrouted = "misrouted"
+	reportMissing   = "missing"
+)
+
+// defaultReportEvents are announced when report_events is not set
+var defaultReportEvents = []string{
+	string(routing.EventSplit),
+	string(routing.EventLinkEstablished),
+	string(routing.EventHubChanged),
+	string(routing.EventConnectFailed),
+	reportMisrouted,
+	reportMissing,
+}
+
+// Announcement rate limiting
+const (
+	defaultReportRate = 10 // Lines per reportWindow
+	reportWindow      = time.Minute
+)
+
+// reporter decides which routing events go to the report channel and
+// limits how fast they are sent
+type reporter struct {
+	channel string
+	events  map[string]bool
+	rate    int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	sent        int
+	suppressed  int
+}
+
+// newReporter returns the reporter for the configured channel, or nil if no
+// report channel is set
+func newReporter(channel string, events []string, rate int) *reporter {
+	if channel == "" {
+		return nil
+	}
+
+	if len(events) == 0 {
+		events = defaultReportEvents
+	}
+	if rate <= 0 {
+		rate = defaultReportRate
+	}
+
+	r := &reporter{
+		channel: channel,
+		events:  make(map[string]bool),
+		rate:    rate,
+	}
+	for _, name := range events {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !validReportEvent(name) {
+			log.Printf("Warning: unknown report event %q ignored", name)
+			continue
+		}
+		r.events[name] = true
+	}
+	return r
+}
+
+// validReportEvent reports whether name is an event type or report type
+func validReportEvent(name string) bool {
+	if name == reportMisrouted || name == reportMissing {
+		return true
+	}
+	for _, t := range routing.EventTypes {
+		if name == string(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// wants reports whether events of the given type are announced
+func (r *reporter) wants(kind string) bool {
+	return r != nil && r.events[kind]
+}
+
+// allow reports whether another line may be sent now. Once the limit is hit,
+// lines are counted instead and flush is called with the count when the
+// window ends.
+func (r *reporter) allow(now time.Time, flush func(suppressed int)) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.windowStart) >= reportWindow {
+		r.windowStart = now
+		r.sent = 0
+	}
+	if r.sent < r.rate {
+		r.sent++
+		return true
+	}
+
+	r.suppressed++
+	if r.suppressed == 1 {
+		time.AfterFunc(r.windowStart.Add(reportWindow).Sub(now), func() {
+			r.mu.Lock()
+			n := r.suppressed
+			r.suppressed = 0
+			r.mu.Unlock()
+			flush(n)
+		})
+	}
+	return false
+}
+
+// joinReportChannel joins the report channel, if one is configured
+func (c *Client) joinReportChannel() {
+	if c.reporter == nil {
+		return
+	}
+	if c.cfg.ReportKey != "" {
+		c.send("JOIN", c.reporter.channel, c.cfg.ReportKey)
+	} else {
+		c.send("JOIN", c.reporter.channel)
+	}
+}
+
+// announce sends a line about an event of the given type to the report
+// channel, if that type is enabled and the rate limit allows
+func (c *Client) announce(kind, text string) {
+	if !c.reporter.wants(kind) {
+		return
+	}
+
+	c.mu.RLock()
+	ready := c.ready
+	c.mu.RUnlock()
+	if !ready {
+		return
+	}
+
+	channel := c.reporter.channel
+	flush := func(suppressed int) {
+		c.privmsg(channel, fmt.Sprintf("... %d more routing events not shown, see !logs", suppressed))
+	}
+	if c.reporter.allow(time.Now(), flush) {
+		c.privmsg(channel, fmt.Sprintf("\x02[%s]\x02 %s", kind, text))
+	}
+}
+
+// announceEvent reports a routing log event
+func (c *Client) announceEvent(event *routing.Event) {
+	c.announce(string(event.Type), fmt.Sprintf("[%s] %s", event.Source, event.Text))
+}
+
+// announceSnapshot reports servers that became misrouted or missing in a
+// LINKS snapshot. The first snapshot only sets the baseline.
+func (c *Client) announceSnapshot(tree *routing.LinkTree) {
+	if c.reporter == nil || tree.Len() == 0 {
+		return
+	}
+
+	c.mu.RLock()
+	rmap := c.routingMap
+	c.mu.RUnlock()
+
+	misrouted := make(map[string]bool)
+	report := routing.CheckCompliance(tree, rmap)
+	for _, e := range report.Filter(routing.Misrouted) {
+		misrouted[e.Server] = true
+	}
+	_, _, missingList := routing.CompareToMap(tree, rmap)
+	missing := make(map[string]bool)
+	for _, server := range missingList {
+		missing[server] = true
+	}
+
+	c.linksMu.Lock()
+	prevMisrouted, prevMissing := c.lastMisrouted, c.lastMissing
+	c.lastMisrouted, c.lastMissing = misrouted, missing
+	c.linksMu.Unlock()
+
+	if prevMisrouted == nil {
+		return
+	}
+
+	for _, e := range report.Filter(routing.Misrouted) {
+		if !prevMisrouted[e.Server] {
+			c.announce(reportMisrouted, fmt.Sprintf("%s is misrouted on %s (should be on: %s)", e.Server, e.Hub, strings.Join(e.Expected, " ")))
+		}
+	}
+
+	var newlyMissing []string
+	for _, server := range missingList {
+		if !prevMissing[server] {
+			newlyMissing = append(newlyMissing, server)
+		}
+	}
+	if len(newlyMissing) > 0 {
+		c.announce(reportMissing, fmt.Sprintf("Missing servers: %s (%d missing in total)", strings.Join(newlyMissing, ", "), len(missingList)))
+	}
+}
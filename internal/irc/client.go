@@ -1,17 +1,19 @@
 package irc
 
 import (
-	"crypto/tls"
 	"fmt"
 	"log"
+	"net"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/dalnet/rnexus/internal/auth"
 	"github.com/dalnet/rnexus/internal/config"
 	"github.com/dalnet/rnexus/internal/routing"
 	"github.com/dalnet/rnexus/internal/storage"
+	"github.com/dalnet/rnexus/internal/webhook"
 	"github.com/ergochat/irc-go/ircevent"
 	"github.com/ergochat/irc-go/ircmsg"
 )
@@ -31,34 +33,88 @@ type Client struct {
 	ready  bool
 	closed bool
 
+	// Outgoing lines, paced to stay under the server's flood limit
+	queue *sendQueue
+
+	// Unread pages of long replies: lowercased nick -> buffer
+	pagesMu sync.Mutex
+	pages   map[string]*pageBuffer
+
+	// Connection tracking for Run
+	readyAt      time.Time
+	disconnected chan struct{}
+	socketMu     sync.Mutex
+	socket       net.Conn // Current TCP connection, closed by Run when it drops
+
+	// Prometheus metrics
+	metrics *clientMetrics
+
+	// Server notices to log, from notice_filters
+	noticeFilters []*noticeFilter
+
+	// Webhooks for routing events, nil if none are configured
+	webhooks *webhook.Notifier
+
 	// Routing data
 	routingMap *routing.Map
-	logs       []string
-	stats      []string
+	mapHistory *routing.MapHistory
+	store      storage.Store
 	motd       *storage.MOTD
 
 	// Oper tracking: hostmask -> is oper
 	opers map[string]bool
-	// Admin session tracking: nick -> is admin
-	admins map[string]bool
+	// Admin session tracking: nick -> logged in account
+	admins   map[string]*adminSession
+	accounts *auth.Accounts
 
 	// Pending WHOIS checks: nick -> {hostmask, message}
 	pendingWhois map[string]*pendingCheck
 
 	// Links collection state
-	linksMu     sync.Mutex
-	linksTree   *routing.LinkTree
-	linksTarget string // Who requested !links
-	linksSummary bool  // Whether this is a !summary request
+	linksMu       sync.Mutex
+	linksTree     *routing.LinkTree
+	linksRequests []linksRequest // Who to answer, none for a scheduled poll
+	linksSent     time.Time
+
+	// Last complete LINKS snapshot, kept for change detection
+	lastLinks   *routing.LinkTree
+	lastLinksAt time.Time
+	pollOnce    sync.Once
+
+	// Report channel announcements, and the misrouted and missing servers
+	// in the last snapshot so only new ones are announced
+	reporter      *reporter
+	lastMisrouted map[string]bool
+	lastMissing   map[string]bool
 
 	// Shutdown/restart callbacks
 	OnShutdown func()
 	OnRestart  func()
 }
 
+// linksMode selects what onLinksEnd reports for a LINKS request
+type linksMode int
+
+const (
+	linksFull       linksMode = iota // !links
+	linksSummary                     // !summary
+	linksCompliance                  // !compliance
+	linksPath                        // !path
+	linksWhatIf                      // !whatif
+	linksFixPlan                     // !fixplan
+)
+
+// linksRequest is a command waiting for the LINKS reply
+type linksRequest struct {
+	nick string
+	mode linksMode
+	args []string // Command arguments, for !path and !whatif
+}
+
 type pendingCheck struct {
 	hostmask string
 	message  string
+	account  string // NickServ account from 307/330, if identified
 }
 
 // NewClient creates a new IRC client
@@ -66,29 +122,59 @@ func NewClient(cfg *config.Config) (*Client, error) {
 	c := &Client{
 		cfg:          cfg,
 		opers:        make(map[string]bool),
-		admins:       make(map[string]bool),
+		admins:       make(map[string]*adminSession),
 		pendingWhois: make(map[string]*pendingCheck),
+		disconnected: make(chan struct{}, 1),
+		pages:        make(map[string]*pageBuffer),
+		metrics:      newClientMetrics(),
+		reporter:     newReporter(cfg.ReportChannel, cfg.ReportEvents, cfg.ReportRate),
 	}
 
 	// Load data files
 	var err error
 	c.routingMap, err = routing.LoadMap(cfg.DataDir)
+	mapLoaded := err == nil
 	if err != nil {
 		log.Printf("Warning: could not load routing map: %v", err)
 		c.routingMap = &routing.Map{Servers: make(map[string][]string)}
 	}
+	for _, p := range c.routingMap.Validate() {
+		log.Printf("Routing map: %s", p)
+	}
 
-	c.logs, err = storage.LoadLogs(cfg.DataDir)
+	c.noticeFilters, err = newNoticeFilters(cfg.NoticeFilters)
 	if err != nil {
-		log.Printf("Warning: could not load logs: %v", err)
+		return nil, fmt.Errorf("invalid notice filters: %w", err)
 	}
 
-	c.stats, err = storage.LoadStats(cfg.DataDir)
+	c.webhooks, err = webhook.New(cfg.Webhooks)
 	if err != nil {
-		log.Printf("Warning: could not load stats: %v", err)
+		return nil, fmt.Errorf("failed to set up webhooks: %w", err)
 	}
 
-	c.motd, err = storage.LoadMOTD(cfg.DataDir)
+	c.mapHistory, err = routing.OpenMapHistory(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open routing map history: %w", err)
+	}
+	if mapLoaded {
+		c.recordMap(c.routingMap, "startup")
+	}
+
+	c.accounts, err = auth.LoadAccounts(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin accounts: %w", err)
+	}
+	if err := checkNickServAdmins(cfg.NickServAdmins); err != nil {
+		return nil, err
+	}
+
+	store, err := storage.Open(cfg.Storage, cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	c.store = &closableStore{store: store}
+
+	c.motd, err = c.store.MOTD()
 	if err != nil {
 		log.Printf("Warning: could not load MOTD: %v", err)
 		c.motd = &storage.MOTD{}
@@ -96,20 +182,26 @@ func NewClient(cfg *config.Config) (*Client, error) {
 
 	// Create IRC connection
 	conn := &ircevent.Connection{
-		Server:       fmt.Sprintf("%s:%d", cfg.Server, cfg.Port),
-		Nick:         cfg.Nick,
-		User:         cfg.Username,
-		RealName:     cfg.IRCName,
-		Password:     cfg.ServerPass,
-		QuitMessage:  "Shutting down",
-		Debug:        false,
-		UseTLS:       false,
-		TLSConfig:    &tls.Config{InsecureSkipVerify: true},
-		SASLLogin:    "",
-		SASLPassword: "",
+		Server:      cfg.ServerList()[0],
+		Nick:        cfg.Nick,
+		User:        cfg.Username,
+		RealName:    cfg.IRCName,
+		Password:    cfg.ServerPass,
+		QuitMessage: "Shutting down",
+		Debug:       false,
+	}
+	if err := configureSecurity(conn, cfg); err != nil {
+		return nil, fmt.Errorf("invalid TLS/SASL settings: %w", err)
+	}
+	conn.DialContext = c.trackSocket(conn.DialContext)
+	if !cfg.TLS && (cfg.ServerPass != "" || cfg.OperPass != "" || cfg.SASLPassword != "") {
+		log.Printf("Warning: tls is off, passwords will be sent in plaintext")
 	}
 	c.conn = conn
 
+	c.queue = newSendQueue(cfg.SendBurst, cfg.SendRate, c.writeLine)
+	go c.queue.run()
+
 	// Register handlers
 	c.registerHandlers()
 
@@ -120,6 +212,7 @@ func (c *Client) registerHandlers() {
 	// Connected (end of MOTD)
 	c.conn.AddCallback("376", c.onConnect)
 	c.conn.AddCallback("422", c.onConnect) // MOTD missing is also "connected"
+	c.conn.AddDisconnectCallback(c.onDisconnect)
 
 	// Private messages
 	c.conn.AddCallback("PRIVMSG", c.onPrivMsg)
@@ -128,43 +221,41 @@ func (c *Client) registerHandlers() {
 	c.conn.AddCallback("NOTICE", c.onNotice)
 
 	// WHOIS responses
+	c.conn.AddCallback("307", c.onWhoisRegNick) // RPL_WHOISREGNICK
 	c.conn.AddCallback("313", c.onWhoisOper)    // RPL_WHOISOPERATOR
+	c.conn.AddCallback("330", c.onWhoisAccount) // RPL_WHOISACCOUNT
 	c.conn.AddCallback("318", c.onWhoisEnd)     // RPL_ENDOFWHOIS
 
 	// LINKS responses
-	c.conn.AddCallback("364", c.onLinks)        // RPL_LINKS
-	c.conn.AddCallback("365", c.onLinksEnd)     // RPL_ENDOFLINKS
+	c.conn.AddCallback("364", c.onLinks)    // RPL_LINKS
+	c.conn.AddCallback("365", c.onLinksEnd) // RPL_ENDOFLINKS
 
 	// Nick issues
-	c.conn.AddCallback("432", c.onNickHeld)     // ERR_ERRONEUSNICKNAME
-	c.conn.AddCallback("433", c.onNickInUse)    // ERR_NICKNAMEINUSE
+	c.conn.AddCallback("432", c.onNickHeld)  // ERR_ERRONEUSNICKNAME
+	c.conn.AddCallback("433", c.onNickInUse) // ERR_NICKNAMEINUSE
 
 	// Nick changes (e.g. services renaming us to a guest nick)
 	c.conn.AddCallback("NICK", c.onNickChange)
 
 	// WATCH logout notification
-	c.conn.AddCallback("601", c.onWatchLogout)  // RPL_LOGOFF
+	c.conn.AddCallback("601", c.onWatchLogout) // RPL_LOGOFF
 
 	// CTCP VERSION
 	c.conn.AddCallback("CTCP_VERSION", c.onCtcpVersion)
 }
 
-// Connect initiates the IRC connection
-func (c *Client) Connect() error {
-	return c.conn.Connect()
-}
-
-// Loop runs the IRC event loop (blocking)
-func (c *Client) Loop() {
-	c.conn.Loop()
-}
-
 // Quit disconnects from IRC
 func (c *Client) Quit(message string) {
 	c.mu.Lock()
 	c.closed = true
 	c.mu.Unlock()
+	c.queue.drain(drainTimeout)
 	c.conn.Quit()
+	c.webhooks.Close(drainTimeout)
+
+	if err := c.store.Close(); err != nil {
+		log.Printf("Error closing storage: %v", err)
+	}
 }
 
 func (c *Client) onConnect(e ircmsg.Message) {
@@ -172,21 +263,30 @@ func (c *Client) onConnect(e ircmsg.Message) {
 
 	// Identify to NickServ
 	if c.cfg.NickPass != "" {
-		c.conn.Privmsg("NickServ@services.dal.net", fmt.Sprintf("IDENTIFY %s %s", c.cfg.Nick, c.cfg.NickPass))
+		c.send("PRIVMSG", "NickServ@services.dal.net", fmt.Sprintf("IDENTIFY %s %s", c.cfg.Nick, c.cfg.NickPass))
 	}
 
 	// OPER up
 	if c.cfg.OperNick != "" && c.cfg.OperPass != "" {
-		c.conn.SendRaw(fmt.Sprintf("OPER %s %s", c.cfg.OperNick, c.cfg.OperPass))
+		c.sendRaw(fmt.Sprintf("OPER %s %s", c.cfg.OperNick, c.cfg.OperPass))
 	}
 
 	// Set user modes (+inFI, -hg)
-	c.conn.Send("MODE", c.conn.CurrentNick(), "+inFI")
-	c.conn.Send("MODE", c.conn.CurrentNick(), "-hg")
+	c.send("MODE", c.conn.CurrentNick(), "+inFI")
+	c.send("MODE", c.conn.CurrentNick(), "-hg")
+
+	c.joinReportChannel()
 
 	c.mu.Lock()
 	c.ready = true
+	c.readyAt = time.Now()
 	c.mu.Unlock()
+	c.metrics.connected.Set(1)
+
+	// Start polling LINKS for topology changes
+	if c.cfg.PollInterval > 0 {
+		c.pollOnce.Do(c.startPolling)
+	}
 
 	log.Println("Bot initialization complete")
 }
@@ -214,7 +314,11 @@ func (c *Client) onPrivMsg(e ircmsg.Message) {
 	isOper := c.opers[hostmask]
 	c.mu.RUnlock()
 
-	if isOper {
+	// NickServ logins always need a fresh WHOIS to see who they are
+	// identified as, as does an automatic login for an oper without a session
+	needAccount := c.nickServAuth() &&
+		(isLoginCommand(message) || (c.cfg.NickServAutoLogin && c.session(nick) == nil))
+	if isOper && !needAccount {
 		// Known oper, process command directly
 		c.handleCommand(nick, hostmask, message)
 	} else {
@@ -225,8 +329,37 @@ func (c *Client) onPrivMsg(e ircmsg.Message) {
 			message:  message,
 		}
 		c.mu.Unlock()
-		c.conn.Send("WHOIS", nick)
+		c.metrics.whoisChecks.Inc()
+		c.send("WHOIS", nick)
+	}
+}
+
+func (c *Client) onWhoisRegNick(e ircmsg.Message) {
+	// 307 <me> <nick> :has identified for this nick
+	if len(e.Params) < 2 {
+		return
 	}
+	nick := e.Params[1]
+
+	c.mu.Lock()
+	if pending := c.pendingWhois[nick]; pending != nil {
+		pending.account = nick
+	}
+	c.mu.Unlock()
+}
+
+func (c *Client) onWhoisAccount(e ircmsg.Message) {
+	// 330 <me> <nick> <account> :is logged in as
+	if len(e.Params) < 3 {
+		return
+	}
+	nick := e.Params[1]
+
+	c.mu.Lock()
+	if pending := c.pendingWhois[nick]; pending != nil {
+		pending.account = e.Params[2]
+	}
+	c.mu.Unlock()
 }
 
 func (c *Client) onWhoisOper(e ircmsg.Message) {
@@ -236,17 +369,13 @@ func (c *Client) onWhoisOper(e ircmsg.Message) {
 	}
 	nick := e.Params[1]
 
+	// The pending command runs at end of WHOIS, once the
+	// identification replies are in as well
 	c.mu.Lock()
-	pending := c.pendingWhois[nick]
-	if pending != nil {
+	if pending := c.pendingWhois[nick]; pending != nil {
 		c.opers[pending.hostmask] = true
 	}
 	c.mu.Unlock()
-
-	// Process the pending command
-	if pending != nil {
-		c.handleCommand(nick, pending.hostmask, pending.message)
-	}
 }
 
 func (c *Client) onWhoisEnd(e ircmsg.Message) {
@@ -267,10 +396,28 @@ func (c *Client) onWhoisEnd(e ircmsg.Message) {
 	}
 	c.mu.Unlock()
 
+	if pending == nil {
+		return
+	}
+
 	// If not an oper, log the attempt
-	if pending != nil && !isOper {
+	if !isOper {
 		c.logCommand(pending.hostmask, fmt.Sprintf("USER - %s", pending.message))
+		return
+	}
+
+	if c.nickServAuth() {
+		if isLoginCommand(pending.message) {
+			c.loginNickServ(nick, pending.hostmask, pending.account, false)
+			return
+		}
+		if c.cfg.NickServAutoLogin && pending.account != "" && c.session(nick) == nil {
+			c.loginNickServ(nick, pending.hostmask, pending.account, true)
+		}
 	}
+
+	// Process the pending command
+	c.handleCommand(nick, pending.hostmask, pending.message)
 }
 
 func (c *Client) onNotice(e ircmsg.Message) {
@@ -287,32 +434,63 @@ func (c *Client) onNotice(e ircmsg.Message) {
 		log.Printf("NickServ: %s", notice)
 	}
 
-	// Check for routing notices from DALnet servers
-	if (strings.HasSuffix(from, "dal.net") || strings.HasSuffix(from, "upenn.edu")) &&
-		strings.Contains(notice, "*** Routing") {
+	// Check for notices wanted by a notice filter (DALnet routing notices
+	// by default)
+	f := c.matchNotice(from, notice)
+	if f == nil {
+		return
+	}
+	notice = strings.TrimPrefix(notice, f.strip)
 
-		// Parse the routing notice
-		notice = strings.TrimPrefix(notice, "*** Routing -- from ")
+	// Extract server name
+	fromServer := from
+	if idx := strings.Index(from, "."); idx > 0 {
+		fromServer = from[:idx]
+	}
 
-		// Extract server name
-		fromServer := from
-		if idx := strings.Index(from, "."); idx > 0 {
-			fromServer = from[:idx]
+	if f.name != routingCategory {
+		record := storage.LogRecord{
+			Time:     time.Now().UTC(),
+			Server:   fromServer,
+			Text:     notice,
+			Category: f.name,
 		}
+		if err := c.store.AppendLog(record); err != nil {
+			log.Printf("Error saving %s logs: %v", f.name, err)
+		}
+		return
+	}
 
-		// Format timestamp
-		timestamp := time.Now().UTC().Format("Mon Jan 02, 2006 15:04:05 GMT")
-		logEntry := fmt.Sprintf("[%s] [%s]: %s", timestamp, fromServer, notice)
+	// Parse the routing notice
+	event := routing.ParseNotice(fromServer, notice, time.Now().UTC())
+	c.recordNoticeMetrics(event)
+	c.addEvent(event)
+}
 
-		c.mu.Lock()
-		c.logs = storage.AddLog(c.logs, logEntry)
-		c.mu.Unlock()
+// addEvent records an event in the routing log, the report channel and
+// webhooks
+func (c *Client) addEvent(event *routing.Event) {
+	record := storage.LogRecord{
+		Time:   event.Time,
+		Server: event.Source,
+		Text:   event.Text,
+	}
+	if err := c.store.AppendLog(record); err != nil {
+		log.Printf("Error saving logs: %v", err)
+	}
+	c.announceEvent(event)
+	c.webhooks.Notify(webhook.NewPayload(event, c.missingCount()))
+}
 
-		// Save to file
-		if err := storage.SaveLogs(c.cfg.DataDir, c.logs); err != nil {
-			log.Printf("Error saving logs: %v", err)
-		}
+// missingCount returns how many map servers were missing from the last
+// LINKS snapshot, or -1 if there is none yet
+func (c *Client) missingCount() int {
+	tree, _ := c.LastLinks()
+	if tree == nil || tree.Len() == 0 {
+		return -1
 	}
+	_, _, missing := routing.CompareToMap(tree, c.RoutingMap())
+	return len(missing)
 }
 
 func (c *Client) onLinks(e ircmsg.Message) {
@@ -346,53 +524,105 @@ func (c *Client) onLinksEnd(e ircmsg.Message) {
 	// 365 <me> <mask> :End of /LINKS list
 	c.linksMu.Lock()
 	tree := c.linksTree
-	target := c.linksTarget
-	summary := c.linksSummary
+	requests := c.linksRequests
 	c.linksTree = nil
-	c.linksTarget = ""
-	c.linksSummary = false
+	c.linksRequests = nil
 	c.linksMu.Unlock()
 
-	if tree == nil || target == "" {
+	if tree == nil {
 		return
 	}
 
+	// Every complete LINKS reply is a snapshot, whether polled or requested
+	c.recordLinks(tree)
+
 	// Get the server we're connected to
 	var connectedServer string
 	if len(e.Params) > 0 {
 		connectedServer = e.Source
 	}
 
+	// A scheduled poll has nobody to reply to
+	for _, req := range requests {
+		c.page(req.nick, c.linksReply(req, tree, connectedServer))
+	}
+}
+
+// linksReply answers one LINKS request from the completed tree
+func (c *Client) linksReply(req linksRequest, tree *routing.LinkTree, connectedServer string) []string {
 	c.mu.RLock()
 	rmap := c.routingMap
 	motd := c.motd
 	c.mu.RUnlock()
 
+	switch req.mode {
+	case linksPath:
+		return pathLines(tree, rmap, req.args[0], req.args[1])
+	case linksWhatIf:
+		return whatIfLines(tree, rmap, req.args[0])
+	case linksFixPlan:
+		return fixPlanLines(routing.PlanFixes(tree, rmap), c.cfg.ConnectPort)
+	case linksCompliance:
+		return complianceLines(routing.CheckCompliance(tree, rmap), true)
+	}
+
+	var lines []string
+
 	// Build and send tree (unless summary mode)
-	if !summary {
-		lines := tree.Build()
-		for _, line := range lines {
-			c.conn.Privmsg(target, line)
-		}
-		c.conn.Privmsg(target, "End of server list.")
-		c.conn.Privmsg(target, fmt.Sprintf("Note - the map displayed above is the network as viewed from my server, %s", connectedServer))
+	if req.mode == linksFull {
+		lines = append(lines, tree.Build()...)
+		lines = append(lines, "End of server list.")
+		lines = append(lines, fmt.Sprintf("Note - the map displayed above is the network as viewed from my server, %s", connectedServer))
 	}
 
 	// Compare against map
 	total, linked, missing := routing.CompareToMap(tree, rmap)
-	c.conn.Privmsg(target, fmt.Sprintf("Total servers: %d", total))
-	c.conn.Privmsg(target, fmt.Sprintf("Linked servers: %d", linked))
+	lines = append(lines, fmt.Sprintf("Total servers: %d", total))
+	lines = append(lines, fmt.Sprintf("Linked servers: %d", linked))
 
 	if len(missing) > 0 {
-		c.conn.Privmsg(target, fmt.Sprintf("Missing servers: %s (%d)", strings.Join(missing, ", "), len(missing)))
+		lines = append(lines, fmt.Sprintf("Missing servers: %s (%d)", strings.Join(missing, ", "), len(missing)))
 	} else {
-		c.conn.Privmsg(target, "No servers are currently missing")
+		lines = append(lines, "No servers are currently missing")
+	}
+
+	if req.mode == linksSummary {
+		lines = append(lines, complianceLines(routing.CheckCompliance(tree, rmap), false)...)
 	}
 
 	// Show MOTD
-	c.conn.Privmsg(target, " ")
-	c.conn.Privmsg(target, fmt.Sprintf("[MOTD] %s", motd.Message))
-	c.conn.Privmsg(target, fmt.Sprintf("MOTD set by %s", motd.Setter))
+	lines = append(lines, " ")
+	lines = append(lines, fmt.Sprintf("[MOTD] %s", motd.Message))
+	lines = append(lines, fmt.Sprintf("MOTD set by %s", motd.Setter))
+	return lines
+}
+
+// complianceLines reports how linked servers sit against their map
+// assignments. In verbose mode every server that is not on its primary hub
+// is listed, otherwise only misrouted servers are.
+func complianceLines(report *routing.ComplianceReport, verbose bool) []string {
+	lines := []string{fmt.Sprintf("Routing compliance: %d on primary, %d on secondary, %d on tertiary, %d misrouted, %d not in map",
+		report.Count(routing.OnPrimary),
+		report.Count(routing.OnSecondary),
+		report.Count(routing.OnTertiary),
+		report.Count(routing.Misrouted),
+		report.Count(routing.Unmapped))}
+
+	for _, e := range report.Entries {
+		switch e.Placement {
+		case routing.OnSecondary, routing.OnTertiary:
+			if verbose {
+				lines = append(lines, fmt.Sprintf("    %s is on its %s hub %s (primary: %s)", e.Server, e.Placement, e.Hub, e.Expected[0]))
+			}
+		case routing.Misrouted:
+			lines = append(lines, fmt.Sprintf("    %s is \x02misrouted\x02 on %s (should be on: %s)", e.Server, e.Hub, strings.Join(e.Expected, " ")))
+		case routing.Unmapped:
+			if verbose {
+				lines = append(lines, fmt.Sprintf("    %s is linked to %s but has no routing map assignment", e.Server, e.Hub))
+			}
+		}
+	}
+	return lines
 }
 
 func (c *Client) onNickHeld(e ircmsg.Message) {
@@ -405,7 +635,7 @@ func (c *Client) onNickHeld(e ircmsg.Message) {
 	// Schedule nick recovery
 	go func() {
 		time.Sleep(15 * time.Second)
-		c.conn.Privmsg("NickServ@services.dal.net", fmt.Sprintf("RELEASE %s %s", c.cfg.Nick, c.cfg.NickPass))
+		c.send("PRIVMSG", "NickServ@services.dal.net", fmt.Sprintf("RELEASE %s %s", c.cfg.Nick, c.cfg.NickPass))
 		time.Sleep(2 * time.Second)
 		c.conn.SetNick(c.cfg.Nick)
 	}()
@@ -421,7 +651,7 @@ func (c *Client) onNickInUse(e ircmsg.Message) {
 	// Schedule nick recovery
 	go func() {
 		time.Sleep(15 * time.Second)
-		c.conn.Privmsg("NickServ@services.dal.net", fmt.Sprintf("GHOST %s %s", c.cfg.Nick, c.cfg.NickPass))
+		c.send("PRIVMSG", "NickServ@services.dal.net", fmt.Sprintf("GHOST %s %s", c.cfg.Nick, c.cfg.NickPass))
 		time.Sleep(2 * time.Second)
 		c.conn.SetNick(c.cfg.Nick)
 	}()
@@ -439,12 +669,12 @@ func (c *Client) onWatchLogout(e ircmsg.Message) {
 	if strings.EqualFold(nick, c.cfg.Nick) {
 		delete(c.admins, nick)
 	}
-	if c.admins[nick] {
+	if c.admins[nick] != nil {
 		delete(c.admins, nick)
 	}
 	c.mu.Unlock()
 
-	c.conn.SendRaw(fmt.Sprintf("WATCH -%s", nick))
+	c.sendRaw(fmt.Sprintf("WATCH -%s", nick))
 }
 
 func (c *Client) onNickChange(e ircmsg.Message) {
@@ -467,17 +697,17 @@ func (c *Client) onNickChange(e ircmsg.Message) {
 		// Regained the primary nick — re-authenticate
 		if c.cfg.NickPass != "" {
 			log.Printf("Regained primary nick %s, identifying with NickServ", c.cfg.Nick)
-			c.conn.Privmsg("NickServ@services.dal.net", fmt.Sprintf("IDENTIFY %s %s", c.cfg.Nick, c.cfg.NickPass))
+			c.send("PRIVMSG", "NickServ@services.dal.net", fmt.Sprintf("IDENTIFY %s %s", c.cfg.Nick, c.cfg.NickPass))
 		}
 	} else if strings.HasPrefix(strings.ToLower(newNick), "guest") {
 		// Services renamed us to a guest nick — re-authenticate and reclaim
 		log.Printf("Renamed to guest nick by services, attempting to re-authenticate and reclaim %s", c.cfg.Nick)
 		if c.cfg.NickPass != "" {
-			c.conn.Privmsg("NickServ@services.dal.net", fmt.Sprintf("IDENTIFY %s %s", c.cfg.Nick, c.cfg.NickPass))
+			c.send("PRIVMSG", "NickServ@services.dal.net", fmt.Sprintf("IDENTIFY %s %s", c.cfg.Nick, c.cfg.NickPass))
 		}
 		go func() {
 			time.Sleep(3 * time.Second)
-			c.conn.Privmsg("NickServ@services.dal.net", fmt.Sprintf("RELEASE %s %s", c.cfg.Nick, c.cfg.NickPass))
+			c.send("PRIVMSG", "NickServ@services.dal.net", fmt.Sprintf("RELEASE %s %s", c.cfg.Nick, c.cfg.NickPass))
 			time.Sleep(2 * time.Second)
 			c.conn.SetNick(c.cfg.Nick)
 		}()
@@ -487,18 +717,25 @@ func (c *Client) onNickChange(e ircmsg.Message) {
 func (c *Client) onCtcpVersion(e ircmsg.Message) {
 	nick := e.Nick()
 	reply := fmt.Sprintf("rnexus %s (built %s, commit %s)", Version, BuildDate, GitCommit)
-	c.conn.SendRaw(fmt.Sprintf("NOTICE %s :\x01VERSION %s\x01", nick, reply))
+	c.sendRaw(fmt.Sprintf("NOTICE %s :\x01VERSION %s\x01", nick, reply))
 }
 
-func (c *Client) logCommand(hostmask, command string) {
-	timestamp := time.Now().UTC().Format("Mon Jan 02, 2006 at 15:04:05 GMT")
-	entry := fmt.Sprintf("%s: %s -> %s", timestamp, hostmask, command)
-
-	c.mu.Lock()
-	c.stats = storage.AddStat(c.stats, entry)
-	c.mu.Unlock()
+// describeUser returns the hostmask, with the account when the sender is
+// logged in
+func (c *Client) describeUser(hostmask string) string {
+	if s := c.session(strings.SplitN(hostmask, "!", 2)[0]); s != nil {
+		return fmt.Sprintf("%s [%s]", hostmask, s.account)
+	}
+	return hostmask
+}
 
-	if err := storage.SaveStats(c.cfg.DataDir, c.stats); err != nil {
+func (c *Client) logCommand(hostmask, command string) {
+	record := storage.StatRecord{
+		Time:    time.Now().UTC(),
+		User:    c.describeUser(hostmask),
+		Command: command,
+	}
+	if err := c.store.AppendStat(record); err != nil {
 		log.Printf("Error saving stats: %v", err)
 	}
 }
@@ -1,12 +1,16 @@
 package irc
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/dalnet/rnexus/internal/auth"
 	"github.com/dalnet/rnexus/internal/routing"
 	"github.com/dalnet/rnexus/internal/storage"
 )
@@ -14,122 +18,211 @@ import (
 // handleCommand processes a command from a verified IRC operator
 func (c *Client) handleCommand(nick, hostmask, message string) {
 	message = strings.TrimSpace(message)
-	cmd := strings.ToLower(strings.Fields(message)[0])
-
-	switch {
-	case cmd == "!help":
-		c.cmdHelp(nick, hostmask, message)
-	case cmd == "!links":
-		c.cmdLinks(nick, hostmask, message, false)
-	case cmd == "!summary":
-		c.cmdLinks(nick, hostmask, message, true)
-	case cmd == "!map":
-		c.cmdMap(nick, hostmask, message)
-	case cmd == "!uplinks":
-		c.cmdUplinks(nick, hostmask, message)
-	case cmd == "!logs":
-		c.cmdLogs(nick, hostmask, message)
-	case cmd == "!logsearch":
-		c.cmdLogSearch(nick, hostmask, message)
-	case cmd == "!motd":
-		c.cmdMotd(nick, hostmask, message)
-	case cmd == "!version":
-		c.cmdVersion(nick, hostmask, message)
-	case cmd == "!login" || cmd == "!su":
-		c.cmdLogin(nick, hostmask, message)
-	case cmd == "!logout":
-		c.cmdLogout(nick, hostmask, message)
-	case cmd == "!set":
-		c.cmdSet(nick, hostmask, message)
-	case cmd == "!reload":
-		c.cmdReload(nick, hostmask, message)
-	case cmd == "!nick":
-		c.cmdNick(nick, hostmask, message)
-	case cmd == "!restart":
-		c.cmdRestart(nick, hostmask, message)
-	case cmd == "!shutdown":
-		c.cmdShutdown(nick, hostmask, message)
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return
+	}
+
+	cmd := commandIndex[strings.ToLower(fields[0])]
+	if cmd == nil {
+		return
 	}
+
+	// A new command replaces any output still queued from the last one
+	if !cmd.keepOutput {
+		c.queue.cancel(nick)
+	}
+
+	c.dispatch(cmd, nick, hostmask, message)
 }
 
 func (c *Client) cmdHelp(nick, hostmask, message string) {
-	c.logCommand(hostmask, message)
-
-	c.conn.Privmsg(nick, "Available commands:")
-	c.conn.Privmsg(nick, "!summary - displays a summary of currently linked/missing servers")
-	c.conn.Privmsg(nick, "!links - shows all currently connected servers, compared to the routing map")
-	c.conn.Privmsg(nick, "!map - displays the most recent routing map")
-	c.conn.Privmsg(nick, "!logs - displays the last 10 routing notices received")
-	c.conn.Privmsg(nick, "!logs <number> - displays the last given number of messages")
-	c.conn.Privmsg(nick, "!logsearch - search logs of routing notices for a given string")
-	c.conn.Privmsg(nick, "!uplinks <server> - shows the primary, secondary and tertiary hubs for the specified server")
-	c.conn.Privmsg(nick, "!motd - displays the MOTD from the routing team")
-	c.conn.Privmsg(nick, "!version - displays bot version information")
+	s := c.session(nick)
 
-	c.mu.RLock()
-	isAdmin := c.admins[nick]
-	c.mu.RUnlock()
+	parts := strings.Fields(message)
+	if len(parts) > 1 {
+		cmd := lookupCommand(parts[1])
+		if cmd == nil {
+			c.privmsg(nick, fmt.Sprintf("No such command %s, try !help", parts[1]))
+			return
+		}
+		c.privmsg(nick, fmt.Sprintf("%s - %s", cmd.usage(), cmd.summary))
+		if len(cmd.aliases) > 0 {
+			c.privmsg(nick, fmt.Sprintf("Also known as: %s", strings.Join(cmd.aliases, ", ")))
+		}
+		if cmd.role != "" {
+			c.privmsg(nick, fmt.Sprintf("Needs an admin account with the %s role", cmd.role))
+		}
+		for _, line := range cmd.details {
+			c.privmsg(nick, line)
+		}
+		return
+	}
 
-	if isAdmin {
-		c.conn.Privmsg(nick, " ")
-		c.conn.Privmsg(nick, "Admin commands:")
-		c.conn.Privmsg(nick, "!set motd <message>")
-		c.conn.Privmsg(nick, "!reload - reload a fresh copy of the current routing map")
-		c.conn.Privmsg(nick, "!nick - if you need to change my nick")
-		c.conn.Privmsg(nick, "!restart")
-		c.conn.Privmsg(nick, "!shutdown")
-		c.conn.Privmsg(nick, "!logout")
+	c.privmsg(nick, "Available commands:")
+	for _, cmd := range commands {
+		if cmd.role == "" {
+			c.privmsg(nick, fmt.Sprintf("%s - %s", cmd.usage(), cmd.summary))
+		}
+	}
+
+	if s != nil {
+		c.privmsg(nick, " ")
+		c.privmsg(nick, fmt.Sprintf("Admin commands (logged in as %s, %s):", s.account, s.role))
+		for _, cmd := range commands {
+			if cmd.role != "" && s.role.Allows(cmd.role) {
+				c.privmsg(nick, fmt.Sprintf("%s - %s", cmd.usage(), cmd.summary))
+			}
+		}
 	}
+	c.privmsg(nick, "Type !help <command> for details")
 }
 
-func (c *Client) cmdLinks(nick, hostmask, message string, summary bool) {
-	c.logCommand(hostmask, message)
+func (c *Client) cmdStop(nick, hostmask, message string) {
+	dropped := c.queue.cancel(nick) + c.dropPages(nick)
+	if dropped == 0 {
+		c.privmsg(nick, "Nothing to stop")
+		return
+	}
+	c.privmsg(nick, fmt.Sprintf("Stopped, %d lines not sent", dropped))
+}
 
+func (c *Client) cmdLinks(nick, hostmask, message string, mode linksMode) {
 	// Reload map before checking
-	c.reloadMap()
+	loader := fmt.Sprintf("%s via %s", c.describeUser(hostmask), strings.Fields(message)[0])
+	if _, err := c.reloadMap(loader); err != nil {
+		log.Printf("Routing map not reloaded: %v", err)
+	}
+
+	req := linksRequest{nick: nick, mode: mode, args: strings.Fields(message)[1:]}
 
 	// Initialize links collection
 	c.linksMu.Lock()
+	if c.collectingLinks() {
+		// A poll or another command is already collecting, answer from
+		// its reply rather than restarting it half way through
+		c.linksRequests = append(c.linksRequests, req)
+		c.linksMu.Unlock()
+		return
+	}
 	c.linksTree = routing.NewLinkTree()
-	c.linksTarget = nick
-	c.linksSummary = summary
+	c.linksRequests = []linksRequest{req}
+	c.linksSent = time.Now()
 	c.linksMu.Unlock()
 
 	// Request LINKS from server
-	c.conn.SendRaw("LINKS")
+	c.sendRaw("LINKS")
 }
 
-func (c *Client) cmdMap(nick, hostmask, message string) {
-	c.logCommand(hostmask, message)
+// pathLines describes the live path between two servers and the path they
+// would have on their primary hubs
+func pathLines(tree *routing.LinkTree, rmap *routing.Map, a, b string) []string {
+	var lines []string
+
+	path, err := tree.Path(a, b)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("No current path: %v", err))
+	} else {
+		lines = append(lines, fmt.Sprintf("Current path (%d hops): %s", len(path)-1, formatPath(path)))
+	}
+
+	primary, err := rmap.PrimaryPath(a, b)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("No primary path: %v", err))
+	} else {
+		lines = append(lines, fmt.Sprintf("On primary hubs (%d hops): %s", len(primary)-1, formatPath(primary)))
+	}
+	return lines
+}
+
+// whatIfLines describes what would happen to the network if hub went down
+func whatIfLines(tree *routing.LinkTree, rmap *routing.Map, hub string) []string {
+	impact, err := routing.SimulateHubFailure(tree, rmap, hub)
+	if err != nil {
+		return []string{fmt.Sprintf("Can't simulate that: %v", err)}
+	}
+
+	if len(impact.Split) == 0 {
+		return []string{fmt.Sprintf("If %s went down, no other servers would be split off", impact.Hub)}
+	}
 
+	lines := []string{fmt.Sprintf("If %s went down, %d servers would be split off: %s", impact.Hub, len(impact.Split), strings.Join(impact.Split, ", "))}
+	for _, r := range impact.Relinks {
+		if r.Hub == "" {
+			lines = append(lines, fmt.Sprintf("    %s has no assigned hub left to relink to (assigned: %s)", r.Server, strings.Join(r.Expected, " ")))
+		} else {
+			lines = append(lines, fmt.Sprintf("    %s should relink to its %s hub %s", r.Server, r.Placement, r.Hub))
+		}
+	}
+	if unassigned := len(impact.Split) - len(impact.Relinks); unassigned > 0 {
+		lines = append(lines, fmt.Sprintf("    %d split servers have no assignment in the routing map", unassigned))
+	}
+	return lines
+}
+
+// fixPlanLines lists a reroute plan for opers to carry out by hand
+func fixPlanLines(plan *routing.FixPlan, port int) []string {
+	if len(plan.Steps) == 0 && len(plan.Skipped) == 0 {
+		return []string{"Nothing to fix, no servers are misrouted or missing"}
+	}
+
+	var lines []string
+	if len(plan.Steps) > 0 {
+		lines = append(lines, "Suggested reroute plan, in order (I will not run these for you):")
+		for i, step := range plan.Steps {
+			lines = append(lines, fmt.Sprintf("  %d. %s", i+1, step.Command(port)))
+		}
+	}
+	for _, note := range plan.Skipped {
+		lines = append(lines, "Can't fix: "+note)
+	}
+	return lines
+}
+
+// formatPath joins server short names with arrows
+func formatPath(path []string) string {
+	names := make([]string, len(path))
+	for i, server := range path {
+		names[i] = server
+		if idx := strings.Index(server, "."); idx > 0 {
+			names[i] = server[:idx]
+		}
+	}
+	return strings.Join(names, " -> ")
+}
+
+func (c *Client) cmdMap(nick, hostmask, message string) {
 	c.mu.RLock()
-	raw := c.routingMap.Raw
+	rmap := c.routingMap
 	c.mu.RUnlock()
 
-	for _, line := range raw {
-		c.conn.Privmsg(nick, line)
+	// Send the file as the routing team wrote it, blank lines kept as
+	// spacers
+	lines := make([]string, len(rmap.Raw))
+	for i, line := range rmap.Raw {
+		if strings.TrimSpace(line) == "" {
+			line = " "
+		}
+		lines[i] = line
 	}
+	c.page(nick, lines)
 }
 
 func (c *Client) cmdUplinks(nick, hostmask, message string) {
-	c.logCommand(hostmask, message)
+	c.mu.RLock()
+	rmap := c.routingMap
+	c.mu.RUnlock()
 
 	parts := strings.Fields(message)
 	if len(parts) < 2 {
 		// Show all servers with hubs
-		c.mu.RLock()
-		raw := c.routingMap.Raw
-		c.mu.RUnlock()
-
-		for _, line := range raw {
-			// Skip header lines
-			if strings.Contains(line, ":") &&
-				!strings.HasPrefix(line, "Tier ") &&
-				!strings.Contains(line, "Routing") &&
-				!strings.HasPrefix(line, "Secondary ") {
-				c.conn.Privmsg(nick, line)
+		var lines []string
+		for _, name := range rmap.ServerList {
+			if entry := rmap.Entry(name); entry != nil {
+				lines = append(lines, entry.String())
 			}
 		}
+		c.page(nick, lines)
 		return
 	}
 
@@ -142,244 +235,363 @@ func (c *Client) cmdUplinks(nick, hostmask, message string) {
 	// Clean up search term
 	server = regexp.MustCompile(`[^\w\s-]`).ReplaceAllString(server, "")
 
-	c.mu.RLock()
-	raw := c.routingMap.Raw
-	c.mu.RUnlock()
+	matches := rmap.FindServer(server)
+	var lines []string
+	for _, name := range matches {
+		entry := rmap.Entry(name)
+		if entry == nil {
+			continue
+		}
+		lines = append(lines, entry.String())
+		lines = append(lines, "    "+describeUplinks(entry))
+	}
 
-	found := 0
-	serverLower := strings.ToLower(server)
-	for _, line := range raw {
-		lineLower := strings.ToLower(line)
-		if strings.HasPrefix(lineLower, serverLower) && strings.Contains(line, ":") {
-			if !strings.HasPrefix(line, "Tier ") &&
-				!strings.Contains(line, "Routing") &&
-				!strings.HasPrefix(line, "Secondary ") {
-				c.conn.Privmsg(nick, line)
-				found++
-			}
+	if len(matches) == 0 {
+		c.privmsg(nick, "No such server found")
+		return
+	}
+	c.page(nick, lines)
+}
+
+// describeUplinks spells out a server's hub priorities and map placement
+func describeUplinks(entry *routing.Server) string {
+	ranks := []string{"primary", "secondary", "tertiary"}
+	var hubs []string
+	for i, hub := range entry.Hubs {
+		rank := fmt.Sprintf("#%d", i+1)
+		if i < len(ranks) {
+			rank = ranks[i]
 		}
+		hubs = append(hubs, fmt.Sprintf("%s %s", rank, hub))
+	}
+	if len(hubs) == 0 {
+		hubs = append(hubs, "no uplinks assigned")
 	}
 
-	if found == 0 {
-		c.conn.Privmsg(nick, "No such server found")
+	desc := strings.Join(hubs, ", ")
+	if entry.Tier > 0 {
+		desc += fmt.Sprintf(" - tier %d %s", entry.Tier, entry.Role)
+	} else {
+		desc += fmt.Sprintf(" - %s", entry.Role)
 	}
+	return desc
 }
 
 func (c *Client) cmdLogs(nick, hostmask, message string) {
-	c.logCommand(hostmask, message)
-
 	parts := strings.Fields(message)
 	count := 10
-	if len(parts) > 1 {
-		if n, err := strconv.Atoi(parts[1]); err == nil && n > 0 {
+	var filterArgs []string
+	for _, arg := range parts[1:] {
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 {
 			count = n
+		} else {
+			filterArgs = append(filterArgs, arg)
 		}
 	}
 
-	c.mu.RLock()
-	logs := c.logs
-	c.mu.RUnlock()
+	filter, err := c.parseLogFilter(filterArgs)
+	if err != nil {
+		c.privmsg(nick, err.Error())
+		return
+	}
 
-	c.conn.Privmsg(nick, fmt.Sprintf("The last \x02%d\x02 routing notices:", count))
+	logs, err := c.store.Logs(filter.query(count))
+	if err != nil {
+		c.privmsg(nick, fmt.Sprintf("Error reading logs: %v", err))
+		return
+	}
 
-	for i := 0; i < count && i < len(logs); i++ {
-		c.conn.Privmsg(nick, logs[i])
+	lines := []string{fmt.Sprintf("The last \x02%d\x02 routing notices%s:", count, filter)}
+	for _, log := range logs {
+		lines = append(lines, log.String())
 	}
+	c.page(nick, lines)
 }
 
 func (c *Client) cmdLogSearch(nick, hostmask, message string) {
-	c.logCommand(hostmask, message)
-
-	parts := strings.SplitN(message, " ", 2)
-	if len(parts) < 2 || strings.TrimSpace(parts[1]) == "" {
-		c.conn.Privmsg(nick, "Please specify a string to search for")
+	parts := strings.Fields(message)
+	filter, err := c.parseLogFilter(parts[1:])
+	if err != nil {
+		c.privmsg(nick, err.Error())
 		return
 	}
 
-	term := strings.TrimSpace(parts[1])
+	if filter.empty() {
+		c.privmsg(nick, "Please specify a string to search for")
+		return
+	}
 
 	// Reject regex special characters
-	if regexp.MustCompile(`[+|*()[\]]`).MatchString(term) {
-		c.conn.Privmsg(nick, "Please try searching without regular expression characters - *+()|[]")
+	if regexp.MustCompile(`[+|*()[\]]`).MatchString(filter.term) {
+		c.privmsg(nick, "Please try searching without regular expression characters - *+()|[]")
 		return
 	}
 
-	c.mu.RLock()
-	logs := c.logs
-	c.mu.RUnlock()
+	logs, err := c.store.Logs(filter.query(maxSearchResults))
+	if err != nil {
+		c.privmsg(nick, fmt.Sprintf("Error reading logs: %v", err))
+		return
+	}
 
-	c.conn.Privmsg(nick, fmt.Sprintf("Displaying search results for \"%s\":", term))
+	lines := []string{fmt.Sprintf("Displaying search results%s:", filter)}
 
-	termLower := strings.ToLower(term)
 	for _, log := range logs {
-		if strings.Contains(strings.ToLower(log), termLower) {
-			c.conn.Privmsg(nick, "    "+log)
+		lines = append(lines, "    "+log.String())
+	}
+
+	if len(logs) == maxSearchResults {
+		lines = append(lines, fmt.Sprintf("Only the newest %d matches are shown, try a narrower search", maxSearchResults))
+	} else {
+		lines = append(lines, "End of matches")
+	}
+	c.page(nick, lines)
+}
+
+// maxSearchResults caps the matches !logsearch reads from the store
+const maxSearchResults = 200
+
+// logFilter narrows routing log entries by event type, server and text, or
+// picks another notice log
+type logFilter struct {
+	typ      routing.EventType
+	server   string
+	term     string
+	category string // Notice filter name, "" for the routing log
+}
+
+// parseLogFilter reads type:<type>, server:<name> and log:<name> arguments;
+// anything else is joined into a case-insensitive search term
+func (c *Client) parseLogFilter(args []string) (logFilter, error) {
+	var f logFilter
+	var terms []string
+
+	for _, arg := range args {
+		lower := strings.ToLower(arg)
+		switch {
+		case strings.HasPrefix(lower, "type:"):
+			f.typ = routing.EventType(strings.TrimPrefix(lower, "type:"))
+			if !validEventType(f.typ) {
+				var types []string
+				for _, t := range routing.EventTypes {
+					types = append(types, string(t))
+				}
+				return f, fmt.Errorf("Unknown event type \"%s\", try one of: %s", f.typ, strings.Join(types, ", "))
+			}
+		case strings.HasPrefix(lower, "server:"):
+			f.server = arg[len("server:"):]
+		case strings.HasPrefix(lower, "log:"):
+			f.category = strings.TrimPrefix(lower, "log:")
+			if f.category == routingCategory {
+				f.category = ""
+			} else if !c.hasNoticeLog(f.category) {
+				return f, fmt.Errorf("Unknown log \"%s\", try one of: %s", f.category, strings.Join(c.NoticeLogs(), ", "))
+			}
+		default:
+			terms = append(terms, arg)
 		}
 	}
 
-	c.conn.Privmsg(nick, "End of matches")
+	f.term = strings.Join(terms, " ")
+	return f, nil
 }
 
-func (c *Client) cmdMotd(nick, hostmask, message string) {
-	c.logCommand(hostmask, message)
+func validEventType(t routing.EventType) bool {
+	for _, known := range routing.EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (f logFilter) empty() bool {
+	return f.typ == "" && f.server == "" && f.term == "" && f.category == ""
+}
+
+// query returns the storage query for the filter, reading at most limit
+// matching records
+func (f logFilter) query(limit int) storage.Query {
+	q := storage.Query{Text: f.term, Category: f.category, Limit: limit}
+	if f.typ != "" || f.server != "" {
+		q.LogFilter = f.match
+	}
+	return q
+}
+
+// match applies the type and server filters; the text term is left to the
+// storage query
+func (f logFilter) match(record storage.LogRecord) bool {
+	if f.typ == "" && f.server == "" {
+		return true
+	}
 
+	event := routing.ParseNotice(record.Server, record.Text, record.Time)
+	if f.typ != "" && event.Type != f.typ {
+		return false
+	}
+	if f.server != "" && !event.Involves(f.server) {
+		return false
+	}
+	return true
+}
+
+// String describes the filter for reply headers
+func (f logFilter) String() string {
+	var desc []string
+	if f.term != "" {
+		desc = append(desc, fmt.Sprintf("\"%s\"", f.term))
+	}
+	if f.typ != "" {
+		desc = append(desc, fmt.Sprintf("type %s", f.typ))
+	}
+	if f.server != "" {
+		desc = append(desc, fmt.Sprintf("server %s", f.server))
+	}
+	if f.category != "" {
+		desc = append(desc, fmt.Sprintf("log %s", f.category))
+	}
+	if len(desc) == 0 {
+		return ""
+	}
+	return " for " + strings.Join(desc, ", ")
+}
+
+func (c *Client) cmdMotd(nick, hostmask, message string) {
 	c.mu.RLock()
 	motd := c.motd
 	c.mu.RUnlock()
 
-	c.conn.Privmsg(nick, motd.Message)
-	c.conn.Privmsg(nick, fmt.Sprintf("MOTD set by %s", motd.Setter))
+	c.privmsg(nick, motd.Message)
+	c.privmsg(nick, fmt.Sprintf("MOTD set by %s", motd.Setter))
 }
 
 func (c *Client) cmdVersion(nick, hostmask, message string) {
-	c.logCommand(hostmask, message)
-
-	c.conn.Privmsg(nick, fmt.Sprintf("rnexus version %s", Version))
-	c.conn.Privmsg(nick, fmt.Sprintf("Built: %s", BuildDate))
-	c.conn.Privmsg(nick, fmt.Sprintf("Commit: %s", GitCommit))
+	c.privmsg(nick, fmt.Sprintf("rnexus version %s", Version))
+	c.privmsg(nick, fmt.Sprintf("Built: %s", BuildDate))
+	c.privmsg(nick, fmt.Sprintf("Commit: %s", GitCommit))
 }
 
 func (c *Client) cmdLogin(nick, hostmask, message string) {
 	parts := strings.Fields(message)
-	if len(parts) < 2 {
-		c.conn.Privmsg(nick, "Usage: !login <password>")
+
+	// The shared admin_pass only works until the first account is created
+	if len(parts) == 2 && c.accounts.Len() == 0 && c.cfg.AdminPass != "" {
+		if parts[1] == c.cfg.AdminPass {
+			c.startSession(nick, &adminSession{account: sharedAccount, role: auth.RoleBotOwner})
+			c.privmsg(nick, "Password accepted, you are now an admin. No accounts exist yet, create one with !account add")
+			c.logCommand(hostmask, "successful login with admin_pass")
+		} else {
+			c.privmsg(nick, "Password incorrect")
+			c.logCommand(hostmask, "INCORRECT LOGIN ATTEMPT")
+		}
 		return
 	}
 
-	password := parts[1]
-
-	if password == c.cfg.AdminPass {
-		c.mu.Lock()
-		c.admins[nick] = true
-		c.mu.Unlock()
+	if len(parts) < 3 {
+		c.privmsg(nick, "Usage: !login <account> <password>")
+		return
+	}
 
-		c.conn.SendRaw(fmt.Sprintf("WATCH +%s", nick))
-		c.conn.Privmsg(nick, "Password accepted, you are now an admin. Type !help for a list of admin-only commands")
+	acct, ok := c.accounts.Verify(parts[1], parts[2])
+	if ok {
+		c.startSession(nick, &adminSession{account: acct.Name, role: acct.Role})
+		c.privmsg(nick, fmt.Sprintf("Password accepted, you are logged in as %s (%s). Type !help for a list of admin-only commands", acct.Name, acct.Role))
 		c.logCommand(hostmask, "successful login")
 	} else {
-		c.conn.Privmsg(nick, "Password incorrect")
-		c.logCommand(hostmask, "INCORRECT LOGIN ATTEMPT")
+		c.privmsg(nick, "Account or password incorrect")
+		c.logCommand(hostmask, fmt.Sprintf("INCORRECT LOGIN ATTEMPT for account %s", parts[1]))
 	}
 }
 
 func (c *Client) cmdLogout(nick, hostmask, message string) {
 	c.mu.Lock()
-	isAdmin := c.admins[nick]
+	isAdmin := c.admins[nick] != nil
 	delete(c.admins, nick)
 	c.mu.Unlock()
 
 	if isAdmin {
-		c.conn.SendRaw(fmt.Sprintf("WATCH -%s", nick))
-		c.conn.Privmsg(nick, "You have been logged out")
-		c.logCommand(hostmask, "logged out")
+		c.sendRaw(fmt.Sprintf("WATCH -%s", nick))
+		c.privmsg(nick, "You have been logged out")
 	} else {
-		c.conn.Privmsg(nick, "You're not logged in!")
-		c.logCommand(hostmask, "tried to log out, but wasn't logged in")
+		c.privmsg(nick, "You're not logged in!")
 	}
 }
 
 func (c *Client) cmdSet(nick, hostmask, message string) {
-	c.mu.RLock()
-	isAdmin := c.admins[nick]
-	c.mu.RUnlock()
+	// Split the way dispatch counted the arguments, so any whitespace works
+	parts := strings.Fields(message)
+	if len(parts) < 3 || !strings.EqualFold(parts[1], "motd") {
+		c.privmsg(nick, "Usage: !set motd <message>")
+		return
+	}
 
-	// Check for !set motd
-	messageLower := strings.ToLower(message)
-	if strings.HasPrefix(messageLower, "!set motd") {
-		if !isAdmin {
-			c.conn.Privmsg(nick, "Sorry, only my admins can change the motd")
-			c.logCommand(hostmask, "tried to change MOTD but wasn't logged in")
-			return
-		}
+	newMotd := afterFields(message, 2)
+	timestamp := time.Now().UTC().Format("Mon Jan 02, 2006 at 15:04:05 GMT")
 
-		// Extract the MOTD message
-		parts := strings.SplitN(message, " ", 3)
-		if len(parts) < 3 {
-			c.conn.Privmsg(nick, "Usage: !set motd <message>")
-			return
-		}
+	motd := &storage.MOTD{
+		Setter:  fmt.Sprintf("%s on %s", nick, timestamp),
+		Message: newMotd,
+	}
 
-		newMotd := strings.TrimSpace(parts[2])
-		timestamp := time.Now().UTC().Format("Mon Jan 02, 2006 at 15:04:05 GMT")
+	c.mu.Lock()
+	c.motd = motd
+	c.mu.Unlock()
 
-		motd := &storage.MOTD{
-			Setter:  fmt.Sprintf("%s on %s", nick, timestamp),
-			Message: newMotd,
-		}
+	if err := c.store.SetMOTD(motd); err != nil {
+		c.privmsg(nick, fmt.Sprintf("Error saving MOTD: %v", err))
+		return
+	}
 
-		c.mu.Lock()
-		c.motd = motd
-		c.mu.Unlock()
+	c.privmsg(nick, fmt.Sprintf("MOTD has been set to \"%s\"", newMotd))
+}
 
-		if err := storage.SaveMOTD(c.cfg.DataDir, motd); err != nil {
-			c.conn.Privmsg(nick, fmt.Sprintf("Error saving MOTD: %v", err))
-			return
+// afterFields returns message without its first n whitespace-separated
+// fields, keeping the spacing of the rest
+func afterFields(message string, n int) string {
+	rest := strings.TrimSpace(message)
+	for i := 0; i < n; i++ {
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			return ""
 		}
-
-		c.conn.Privmsg(nick, fmt.Sprintf("MOTD has been set to \"%s\"", newMotd))
-		c.logCommand(hostmask, fmt.Sprintf("changed MOTD to \"%s\"", newMotd))
+		rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
 	}
+	return rest
 }
 
 func (c *Client) cmdReload(nick, hostmask, message string) {
-	c.mu.RLock()
-	isAdmin := c.admins[nick]
-	c.mu.RUnlock()
+	c.privmsg(nick, "Reloading routing map...")
+	problems, err := c.reloadMap(c.describeUser(hostmask))
 
-	if !isAdmin {
-		c.conn.Privmsg(nick, "Sorry, only my admins can issue that command")
-		c.logCommand(hostmask, "tried to reload routing map, but wasn't logged in")
-		return
+	var lines []string
+	for _, p := range problems {
+		lines = append(lines, p.String())
 	}
-
-	c.conn.Privmsg(nick, "Reloading routing map...")
-	c.reloadMap()
-	c.conn.Privmsg(nick, "Done.")
-	c.logCommand(hostmask, "reloaded routing map")
+	if err != nil {
+		log.Printf("Routing map not reloaded: %v", err)
+		lines = append(lines, fmt.Sprintf("Not reloaded, still using the previous map: %v", err))
+	} else {
+		latest := "no saved version"
+		if versions := c.mapHistory.Versions(); len(versions) > 0 {
+			latest = versions[len(versions)-1].String()
+		}
+		lines = append(lines, fmt.Sprintf("Done, %d problems found. Map is %s.", len(problems), latest))
+	}
+	c.page(nick, lines)
 }
 
 func (c *Client) cmdNick(nick, hostmask, message string) {
-	c.mu.RLock()
-	isAdmin := c.admins[nick]
-	c.mu.RUnlock()
-
-	parts := strings.Fields(message)
-	newNick := ""
-	if len(parts) > 1 {
-		newNick = parts[1]
-	}
-
-	if !isAdmin {
-		c.conn.Privmsg(nick, "Sorry, only my admins can change my nick")
-		c.logCommand(hostmask, fmt.Sprintf("nick change command to %s, not logged in", newNick))
-		return
-	}
-
-	if newNick == "" {
-		c.conn.Privmsg(nick, "Usage: !nick <newnick>")
-		return
-	}
+	newNick := strings.Fields(message)[1]
 
 	c.conn.SetNick(newNick)
 	time.AfterFunc(time.Second, func() {
-		c.conn.Privmsg(nick, fmt.Sprintf("Changed nick to %s", newNick))
+		c.privmsg(nick, fmt.Sprintf("Changed nick to %s", newNick))
 	})
-	c.logCommand(hostmask, fmt.Sprintf("nick change command to %s", newNick))
 }
 
 func (c *Client) cmdRestart(nick, hostmask, message string) {
-	c.mu.RLock()
-	isAdmin := c.admins[nick]
-	c.mu.RUnlock()
-
-	if !isAdmin {
-		c.conn.Privmsg(nick, "Sorry, only my admins can restart me")
-		c.logCommand(hostmask, "issued the restart command but wasn't logged in")
-		return
-	}
-
-	c.logCommand(hostmask, "restart command")
-	c.conn.Privmsg(nick, "Restarting")
+	// Quit sends what is queued before disconnecting
+	c.privmsg(nick, "Restarting")
 
 	if c.OnRestart != nil {
 		c.OnRestart()
@@ -387,31 +599,34 @@ func (c *Client) cmdRestart(nick, hostmask, message string) {
 }
 
 func (c *Client) cmdShutdown(nick, hostmask, message string) {
-	c.mu.RLock()
-	isAdmin := c.admins[nick]
-	c.mu.RUnlock()
-
-	if !isAdmin {
-		c.conn.Privmsg(nick, "Sorry, only my admins can shut me down")
-		c.logCommand(hostmask, "issued the shutdown command but wasn't logged in")
-		return
-	}
-
-	c.logCommand(hostmask, message)
-	c.conn.Privmsg(nick, "Shutting down")
+	c.privmsg(nick, "Shutting down")
 
 	if c.OnShutdown != nil {
 		c.OnShutdown()
 	}
 }
 
-func (c *Client) reloadMap() {
+// errMapInvalid is returned by reloadMap when the new map has errors
+var errMapInvalid = errors.New("the routing map has errors")
+
+// reloadMap reads rmap.txt again and swaps it in unless validation finds
+// errors, in which case the current map is kept. A map that differs from the
+// last one is saved as a new version under loader. Returns the problems found.
+func (c *Client) reloadMap(loader string) ([]routing.Problem, error) {
 	rmap, err := routing.LoadMap(c.cfg.DataDir)
 	if err != nil {
-		return
+		return nil, fmt.Errorf("failed to read routing map: %w", err)
+	}
+
+	problems := rmap.Validate()
+	if routing.HasErrors(problems) {
+		return problems, errMapInvalid
 	}
 
 	c.mu.Lock()
 	c.routingMap = rmap
 	c.mu.Unlock()
+
+	c.recordMap(rmap, loader)
+	return problems, nil
 }
@@ -0,0 +1,27 @@
+package irc
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+// exportTopology writes the live topology from a LINKS snapshot and the
+// routing map's intended topology to data_dir/topology
+func (c *Client) exportTopology(tree *routing.LinkTree) {
+	if !c.cfg.ExportTopology || tree.Len() == 0 {
+		return
+	}
+
+	c.mu.RLock()
+	rmap := c.routingMap
+	c.mu.RUnlock()
+
+	dir := filepath.Join(c.cfg.DataDir, routing.TopologyDir)
+	for _, topo := range []*routing.Topology{tree.Topology(rmap), rmap.Topology()} {
+		if err := topo.WriteFiles(dir); err != nil {
+			log.Printf("Failed to export %s topology: %v", topo.Kind, err)
+		}
+	}
+}
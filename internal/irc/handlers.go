@@ -4,6 +4,19 @@ package irc
 // The actual handler implementations are split across:
 // - client.go: Connection lifecycle, WHOIS, LINKS, NOTICE handlers
 // - commands.go: Bot command implementations
+// - registry.go: Command declarations, permission and argument checks
+// - poll.go: Scheduled LINKS polling and change detection
+// - export.go: Topology files written after each LINKS
+// - history.go: Routing map versions, !mapdiff and !mapversions
+// - accounts.go: Admin sessions, role checks and account management
+// - announce.go: Routing event announcements in the report channel
+// - queue.go: Outgoing line queue with flood control
+// - pages.go: Paginated replies for !more
+// - reconnect.go: Connection loop with backoff and server failover
+// - tls.go: TLS dialing and verification, client certificates and SASL
+// - status.go: Read-only accessors for the status page (internal/web)
+// - metrics.go: Prometheus counters and gauges for /metrics
+// - notices.go: Configurable server notice filters
 
 /*
 Handler Summary:
@@ -13,33 +26,106 @@ Connection Events:
   - Identifies to NickServ
   - OPERs up
   - Sets user modes (+inFI, -hg)
+  - Joins report_channel, if set
+- Disconnect (onDisconnect): Connection dropped
+  - Wakes Run, which closes the old socket, clears ready, opers, admin
+    sessions, pending WHOIS and any LINKS in progress, then reconnects
+  - Backoff doubles from 5s to 5m with jitter; a connection that stayed up
+    for 2m resets it
+  - After 3 failures in a row the next server in servers is tried
 
 Private Messages:
 - PRIVMSG (onPrivMsg): Handles private messages from users
   - Checks if sender is known IRC operator (cached)
   - If not known, initiates WHOIS check
+  - With auth_mode: nickserv, !login and (with auto-login) any command from
+    an oper without a session also go through WHOIS
   - If known oper, routes to command handler
 
 WHOIS Responses:
+- 307/330 (onWhoisRegNick/onWhoisAccount): NickServ identification
+  - Records the account the user is identified to
 - 313 (onWhoisOper): RPL_WHOISOPERATOR - User is an IRC operator
   - Caches oper status by hostmask
-  - Processes pending command
 - 318 (onWhoisEnd): RPL_ENDOFWHOIS - End of WHOIS response
   - Cleans up pending check
   - Logs non-oper access attempts
+  - Processes pending command for opers
+  - With auth_mode: nickserv, handles !login and auto-login
 
 Server Notices:
 - NOTICE (onNotice): Handles server notices
-  - Filters for routing notices from DALnet servers
-  - Parses and logs routing information
+  - Matched against notice_filters in order (DALnet routing notices from
+    dal.net and upenn.edu servers by default); notices from users never match
+  - Notices of the "routing" filter are parsed into typed events
+    (routing.ParseNotice) and logged
+  - Other filters' notices go to their own log, shown by !logs log:<name>;
+    names that aren't configured filters are rejected
+  - A notice_filters list without a "routing" filter is warned about at
+    startup, since routing events are then never logged
 
 LINKS Responses:
 - 364 (onLinks): RPL_LINKS - Server link information
   - Collects server topology data
 - 365 (onLinksEnd): RPL_ENDOFLINKS - End of LINKS response
+  - The routing map is reloaded when the request is sent, unless the new
+    map has errors (!reload shows them)
   - Builds and displays server tree
   - Compares against routing map
   - Shows missing servers
+  - Reports routing compliance for !summary and !compliance
+  - Reports the current and primary-hub path between two servers for !path
+  - Simulates a hub going down for !whatif
+  - Prints a SQUIT/CONNECT reroute plan for !fixplan (never executed)
+  - Keeps the tree as the latest snapshot and logs changes since the last one
+  - Commands sent while a reply is being collected wait for it and are all
+    answered from it; LINKS is never re-sent half way through a reply
+
+LINKS Polling (poll.go):
+- Sends LINKS every poll_interval seconds once connected
+  - Replies are collected like !links but not sent to anyone
+  - Servers that appeared, disappeared or changed hub go to the routing log
+  - A LINKS reply (polled or !links) with no 365 after 60s is dropped so the
+    next poll or command starts over
+  - With export_topology, the live and map topologies are written to
+    data_dir/topology as DOT, JSON and Mermaid
+
+Output Queue (queue.go):
+- Every line to the server goes through the send queue
+  - Protocol lines (WHOIS, LINKS, OPER, MODE, NickServ) go first
+  - Replies are sent round robin between targets
+  - A global token bucket (send_burst, send_rate) and a smaller one per
+    target pace the output
+  - A user's queued replies are dropped when they send a new command,
+    or by !stop
+  - On !shutdown and !restart, queued lines get up to 5s to go out before
+    QUIT
+  - PONG is answered by ircevent directly, never queued
+
+Pagination (pages.go):
+- Long replies (!links, !summary, !compliance, !map, !uplinks, !logs,
+  !logsearch, !mapdiff, !mapversions) are sent page_size lines at a time
+  - The rest is kept per nick for !more, until !stop, a new long reply,
+    or 10 minutes without a !more
+
+Map History (history.go):
+- Every distinct routing map loaded (at startup, by !reload or before a
+  LINKS command) is saved to data_dir/maps with who loaded it, when, and
+  its SHA-256
+  - !mapdiff compares two versions: servers added, removed and reassigned
+  - !mapversions <server> lists the versions that changed a server's hubs
+
+Report Channel (announce.go):
+- Routing log events of the types in report_events are announced in
+  report_channel, as are servers newly misrouted or missing in a snapshot
+  - At most report_rate lines a minute; the rest are counted and summed up
+    when the minute is over
+
+Webhooks (internal/webhook):
+- Routing events from notices and LINKS polls are POSTed to each webhook
+  whose events include their type (split, link and moved by default)
+  - Each webhook has its own queue; 429, 5xx and network errors are retried
+  - Bodies are signed with HMAC-SHA256 when a secret is set
 
 Nick Issues:
 - 432 (onNickHeld): ERR_ERRONEUSNICKNAME - Nick is held
@@ -49,9 +135,23 @@ Nick Issues:
   - Switches to alternate nick
   - Schedules GHOST and nick change
 
+Commands (registry.go):
+- Each command declares its name, aliases, arguments, summary, details and
+  required role; handleCommand looks it up and dispatch
+  - Checks the role, replying "Sorry, only my admins can use ..." if not
+  - Replies with the generated usage when the arguments don't fit
+  - Logs the command to stats, with secret arguments masked
+- !help is generated from the declarations; !help <command> shows details
+
 Admin Session:
 - 601 (onWatchLogout): RPL_LOGOFF - WATCH notification
   - Auto-logs out admin if they quit/change nick
+- Sessions are tied to a named account from accounts.txt with a role:
+  - viewer: no admin commands beyond !passwd
+  - routing-admin: !set motd, !reload
+  - bot-owner: everything, including !nick, !restart, !shutdown, !account
+- With auth_mode: nickserv, !login takes no password; the identified
+  NickServ account is looked up in nickserv_admins for its role
 
 CTCP:
 - CTCP_VERSION: Responds with bot version information
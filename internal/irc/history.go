@@ -0,0 +1,173 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+// recordMap saves rmap as a new version if it changed since the last one.
+// An empty map (rmap.txt missing) is not a version.
+func (c *Client) recordMap(rmap *routing.Map, loader string) {
+	if len(rmap.Raw) == 0 {
+		return
+	}
+	v, added, err := c.mapHistory.Record(rmap, loader, time.Now())
+	if err != nil {
+		log.Printf("Failed to save routing map version: %v", err)
+		return
+	}
+	if added {
+		log.Printf("Routing map saved as %s", v)
+	}
+}
+
+// cmdMapDiff compares two saved versions of the routing map. With no
+// versions it compares the latest with the one before, with one it compares
+// that version with the latest.
+func (c *Client) cmdMapDiff(nick, hostmask, message string) {
+	versions := c.mapHistory.Versions()
+	if len(versions) < 2 {
+		c.privmsg(nick, "Only one version of the routing map has been loaded, nothing to compare")
+		return
+	}
+
+	from, to := versions[len(versions)-2].Version, versions[len(versions)-1].Version
+	args := strings.Fields(message)[1:]
+	var err error
+	if len(args) > 0 {
+		if from, err = parseMapVersion(args[0]); err != nil {
+			c.privmsg(nick, err.Error())
+			return
+		}
+	}
+	if len(args) > 1 {
+		if to, err = parseMapVersion(args[1]); err != nil {
+			c.privmsg(nick, err.Error())
+			return
+		}
+	}
+
+	d, err := c.diffVersions(from, to)
+	if err != nil {
+		c.privmsg(nick, err.Error())
+		return
+	}
+	c.page(nick, d.lines(""))
+}
+
+// cmdMapVersions lists the saved versions of the routing map, newest first.
+// With a server, only versions that changed its hubs are listed.
+func (c *Client) cmdMapVersions(nick, hostmask, message string) {
+	versions := c.mapHistory.Versions()
+	args := strings.Fields(message)[1:]
+
+	if len(args) == 0 {
+		lines := []string{fmt.Sprintf("\x02%d\x02 versions of the routing map:", len(versions))}
+		for i := len(versions) - 1; i >= 0; i-- {
+			lines = append(lines, fmt.Sprintf("  %s", versions[i]))
+		}
+		c.page(nick, lines)
+		return
+	}
+
+	server := args[0]
+	if idx := strings.Index(server, "."); idx > 0 {
+		server = server[:idx]
+	}
+	lines := []string{fmt.Sprintf("Routing map changes for \x02%s\x02:", server)}
+	for i := len(versions) - 1; i > 0; i-- {
+		d, err := c.diffVersions(versions[i-1].Version, versions[i].Version)
+		if err != nil {
+			lines = append(lines, err.Error())
+			continue
+		}
+		if d.Involves(server) {
+			lines = append(lines, fmt.Sprintf("  %s", versions[i]))
+			// Skip the header
+			for _, change := range d.lines(server)[1:] {
+				lines = append(lines, "  "+change)
+			}
+		}
+	}
+	if len(lines) == 1 {
+		lines = append(lines, "No changes in the saved versions")
+	}
+	c.page(nick, lines)
+}
+
+// versionDiff is the difference between two saved versions of the map
+type versionDiff struct {
+	*routing.MapDiff
+	from, to       routing.MapVersion
+	oldMap, newMap *routing.Map
+}
+
+// diffVersions loads and compares two saved versions of the map
+func (c *Client) diffVersions(from, to int) (*versionDiff, error) {
+	d := &versionDiff{}
+	var ok bool
+	if d.from, ok = c.mapHistory.Version(from); !ok {
+		return nil, fmt.Errorf("No routing map version %d, see !mapversions", from)
+	}
+	if d.to, ok = c.mapHistory.Version(to); !ok {
+		return nil, fmt.Errorf("No routing map version %d, see !mapversions", to)
+	}
+	var err error
+	if d.oldMap, err = c.mapHistory.Load(from); err != nil {
+		return nil, fmt.Errorf("Error loading routing map: %v", err)
+	}
+	if d.newMap, err = c.mapHistory.Load(to); err != nil {
+		return nil, fmt.Errorf("Error loading routing map: %v", err)
+	}
+	d.MapDiff = routing.DiffMaps(d.oldMap, d.newMap)
+	return d, nil
+}
+
+// lines describes the differences. With a server, only changes to that
+// server are included.
+func (d *versionDiff) lines(server string) []string {
+	lines := []string{fmt.Sprintf("Routing map %s -> %s:", d.from, d.to)}
+	matches := func(name string) bool {
+		return server == "" || strings.EqualFold(name, server)
+	}
+
+	for _, name := range d.Added {
+		if matches(name) {
+			lines = append(lines, fmt.Sprintf("  added %s: %s", name, strings.Join(d.newMap.Servers[name], " ")))
+		}
+	}
+	for _, name := range d.Removed {
+		if matches(name) {
+			lines = append(lines, fmt.Sprintf("  removed %s (was on %s)", name, strings.Join(d.oldMap.Servers[name], " ")))
+		}
+	}
+	for _, change := range d.Changed {
+		if !matches(change.Server) {
+			continue
+		}
+		line := fmt.Sprintf("  %s: %s -> %s", change.Server, strings.Join(change.Old, " "), strings.Join(change.New, " "))
+		if change.PrimaryChanged() {
+			line += " (primary hub changed)"
+		}
+		lines = append(lines, line)
+	}
+
+	if server == "" && d.Empty() {
+		lines = append(lines, "  No changes to hub assignments")
+	}
+	return lines
+}
+
+// parseMapVersion reads a version number given as "3" or "v3"
+func parseMapVersion(arg string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(arg), "v"))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("Invalid map version \"%s\", see !mapversions", arg)
+	}
+	return n, nil
+}
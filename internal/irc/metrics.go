@@ -0,0 +1,74 @@
+package irc
+
+import (
+	"time"
+
+	"github.com/dalnet/rnexus/internal/metrics"
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+// clientMetrics are the bot's Prometheus metrics, served on /metrics
+type clientMetrics struct {
+	registry *metrics.Registry
+
+	linked    *metrics.Gauge
+	missing   *metrics.Gauge
+	misrouted *metrics.Gauge
+	lastLinks *metrics.Gauge
+
+	noticesByType   *metrics.Counter
+	noticesByServer *metrics.Counter
+	commands        *metrics.Counter
+	whoisChecks     *metrics.Counter
+
+	connected  *metrics.Gauge
+	reconnects *metrics.Counter
+}
+
+func newClientMetrics() *clientMetrics {
+	r := metrics.NewRegistry()
+	return &clientMetrics{
+		registry: r,
+
+		linked:    r.Gauge("rnexus_servers_linked", "Servers in the last LINKS snapshot"),
+		missing:   r.Gauge("rnexus_servers_missing", "Servers in the routing map missing from the last LINKS snapshot"),
+		misrouted: r.Gauge("rnexus_servers_misrouted", "Servers linked to a hub they are not assigned to in the routing map"),
+		lastLinks: r.Gauge("rnexus_last_links_timestamp_seconds", "Unix time of the last complete LINKS reply"),
+
+		noticesByType:   r.Counter("rnexus_routing_notices_total", "Routing notices received, by event type", "type"),
+		noticesByServer: r.Counter("rnexus_routing_notices_by_server_total", "Routing notices received, by reporting server", "server"),
+		commands:        r.Counter("rnexus_commands_total", "Commands handled, by name", "command"),
+		whoisChecks:     r.Counter("rnexus_whois_checks_total", "WHOIS checks sent to verify opers"),
+
+		connected:  r.Gauge("rnexus_connected", "1 when connected and registered with the IRC server"),
+		reconnects: r.Counter("rnexus_reconnects_total", "Reconnection attempts after the connection was lost or failed"),
+	}
+}
+
+// Metrics returns the registry for the /metrics endpoint
+func (c *Client) Metrics() *metrics.Registry {
+	return c.metrics.registry
+}
+
+// recordSnapshotMetrics updates the gauges from a complete LINKS snapshot
+func (c *Client) recordSnapshotMetrics(tree *routing.LinkTree, at time.Time) {
+	c.mu.RLock()
+	rmap := c.routingMap
+	c.mu.RUnlock()
+
+	_, _, missing := routing.CompareToMap(tree, rmap)
+	c.metrics.linked.Set(float64(tree.Len()))
+	c.metrics.missing.Set(float64(len(missing)))
+	c.metrics.misrouted.Set(float64(routing.CheckCompliance(tree, rmap).Count(routing.Misrouted)))
+	c.metrics.lastLinks.Set(float64(at.Unix()))
+}
+
+// recordNoticeMetrics counts a routing notice by type and reporting server
+func (c *Client) recordNoticeMetrics(event *routing.Event) {
+	reporter := event.Reporter
+	if reporter == "" {
+		reporter = event.Source
+	}
+	c.metrics.noticesByType.Inc(string(event.Type))
+	c.metrics.noticesByServer.Inc(reporter)
+}
@@ -0,0 +1,123 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/dalnet/rnexus/internal/config"
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+// routingCategory is the notice filter whose notices go to the routing log
+const routingCategory = "routing"
+
+// defaultNoticeFilters are used when notice_filters is not set: DALnet
+// routing notices, as they have always been recognised
+var defaultNoticeFilters = []config.NoticeFilter{{
+	Name:    routingCategory,
+	Sources: []string{"dal.net", "upenn.edu"},
+	Match:   "*** Routing",
+	Strip:   "*** Routing -- from ",
+}}
+
+// noticeFilter is a compiled config.NoticeFilter
+type noticeFilter struct {
+	name    string
+	sources []func(string) bool
+	match   func(string) bool
+	strip   string
+}
+
+// newNoticeFilters compiles the configured notice filters, warning when none
+// of them is the routing filter
+func newNoticeFilters(cfgs []config.NoticeFilter) ([]*noticeFilter, error) {
+	if len(cfgs) == 0 {
+		cfgs = defaultNoticeFilters
+	}
+
+	var filters []*noticeFilter
+	hasRouting := false
+	for i, cfg := range cfgs {
+		name := strings.ToLower(strings.TrimSpace(cfg.Name))
+		if !storage.ValidCategory(name) {
+			return nil, fmt.Errorf("notice filter %d: name %q must be lowercase letters, digits, - or _", i+1, cfg.Name)
+		}
+
+		f := &noticeFilter{name: name, strip: cfg.Strip}
+		for _, source := range cfg.Sources {
+			match, err := matcher(source, cfg.Regex, strings.HasSuffix)
+			if err != nil {
+				return nil, fmt.Errorf("notice filter %s: source %q: %w", name, source, err)
+			}
+			f.sources = append(f.sources, match)
+		}
+		match, err := matcher(cfg.Match, cfg.Regex, strings.Contains)
+		if err != nil {
+			return nil, fmt.Errorf("notice filter %s: match %q: %w", name, cfg.Match, err)
+		}
+		f.match = match
+		filters = append(filters, f)
+		hasRouting = hasRouting || name == routingCategory
+	}
+
+	if !hasRouting {
+		log.Printf("Warning: no %q notice filter in notice_filters, routing notices will not be logged or announced", routingCategory)
+	}
+	return filters, nil
+}
+
+// matcher returns a function testing text against pattern, as a regular
+// expression or with the given plain text comparison
+func matcher(pattern string, regex bool, plain func(s, pattern string) bool) (func(string) bool, error) {
+	if !regex {
+		return func(s string) bool { return plain(s, pattern) }, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+// matches reports whether a notice from source is one for this filter
+func (f *noticeFilter) matches(source, text string) bool {
+	if len(f.sources) > 0 {
+		found := false
+		for _, match := range f.sources {
+			if match(source) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return f.match(text)
+}
+
+// hasNoticeLog reports whether name is a configured notice filter
+func (c *Client) hasNoticeLog(name string) bool {
+	for _, f := range c.noticeFilters {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matchNotice returns the first filter matching a server notice, or nil
+func (c *Client) matchNotice(source, text string) *noticeFilter {
+	// Notices from users are never server notices
+	if strings.Contains(source, "!") {
+		return nil
+	}
+	for _, f := range c.noticeFilters {
+		if f.matches(source, text) {
+			return f
+		}
+	}
+	return nil
+}
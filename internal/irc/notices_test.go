@@ -0,0 +1,71 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/dalnet/rnexus/internal/config"
+)
+
+func TestNoticeFilters(t *testing.T) {
+	filters, err := newNoticeFilters(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{noticeFilters: filters}
+	if f := c.matchNotice("hub1.dal.net", "*** Routing -- from hub1: lost link"); f == nil || f.name != routingCategory {
+		t.Errorf("Expected the default filter to match a routing notice, got %+v", f)
+	}
+	if f := c.matchNotice("irc.example.org", "*** Routing -- from hub1: lost link"); f != nil {
+		t.Errorf("Expected a notice from another network not to match")
+	}
+	if f := c.matchNotice("nick!user@host.dal.net", "*** Routing -- fake"); f != nil {
+		t.Errorf("Expected a notice from a user not to match")
+	}
+
+	filters, err = newNoticeFilters([]config.NoticeFilter{
+		{Name: "routing", Sources: []string{"dal.net"}, Match: "*** Routing"},
+		{Name: "Notice", Regex: true, Match: `^\*\*\* Notice -- `},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.noticeFilters = filters
+	if f := c.matchNotice("irc.example.org", "*** Notice -- Client connecting"); f == nil || f.name != "notice" {
+		t.Errorf("Expected the notice filter to match, got %+v", f)
+	}
+	if f := c.matchNotice("irc.example.org", "Welcome *** Notice -- "); f != nil {
+		t.Errorf("Expected an anchored regex not to match")
+	}
+
+	if _, err := newNoticeFilters([]config.NoticeFilter{{Name: "bad", Regex: true, Match: "("}}); err == nil {
+		t.Error("Expected an invalid regex to be rejected")
+	}
+	if _, err := newNoticeFilters([]config.NoticeFilter{{Name: "../logs", Match: "x"}}); err == nil {
+		t.Error("Expected an invalid name to be rejected")
+	}
+}
+
+func TestLogFilterNoticeLogs(t *testing.T) {
+	filters, err := newNoticeFilters([]config.NoticeFilter{
+		{Name: "routing", Match: "*** Routing"},
+		{Name: "notice", Match: "*** Notice"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{noticeFilters: filters}
+
+	f, err := c.parseLogFilter([]string{"log:Notice"})
+	if err != nil || f.category != "notice" {
+		t.Fatalf("Expected the notice log, got %+v, %v", f, err)
+	}
+	if f.empty() {
+		t.Error("Expected a log: filter alone to be enough for !logsearch")
+	}
+	if f, err := c.parseLogFilter([]string{"log:routing"}); err != nil || f.category != "" {
+		t.Errorf("Expected log:routing to read the routing log, got %+v, %v", f, err)
+	}
+	if _, err := c.parseLogFilter([]string{"log:nosuchlog"}); err == nil {
+		t.Error("Expected an unconfigured log to be rejected")
+	}
+}
@@ -0,0 +1,118 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Pagination defaults
+const (
+	defaultPageSize = 15
+	pageExpiry      = 10 * time.Minute // Unread pages are dropped after this
+)
+
+// pageBuffer holds the rest of a long reply until the user asks for it
+type pageBuffer struct {
+	lines   []string
+	next    int // Index of the first unsent line
+	size    int
+	touched time.Time
+}
+
+// position returns the page number of the next unsent line and the total
+func (b *pageBuffer) position() (int, int) {
+	return b.next/b.size + 1, (len(b.lines) + b.size - 1) / b.size
+}
+
+// pageSize returns the configured lines per page
+func (c *Client) pageSize() int {
+	if c.cfg.PageSize > 0 {
+		return c.cfg.PageSize
+	}
+	return defaultPageSize
+}
+
+// page sends lines to nick one page at a time, keeping the rest for !more.
+// Any earlier unread pages for nick are replaced.
+func (c *Client) page(nick string, lines []string) {
+	key := strings.ToLower(nick)
+	size := c.pageSize()
+
+	c.pagesMu.Lock()
+	now := time.Now()
+	for k, b := range c.pages {
+		if now.Sub(b.touched) > pageExpiry {
+			delete(c.pages, k)
+		}
+	}
+	if len(lines) <= size {
+		delete(c.pages, key)
+		c.pagesMu.Unlock()
+		for _, line := range lines {
+			c.privmsg(nick, line)
+		}
+		return
+	}
+	c.pages[key] = &pageBuffer{lines: lines, size: size, touched: now}
+	c.pagesMu.Unlock()
+
+	c.sendPage(nick)
+}
+
+// sendPage sends the next page from nick's buffer. Returns false if there
+// was nothing left to send.
+func (c *Client) sendPage(nick string) bool {
+	key := strings.ToLower(nick)
+
+	c.pagesMu.Lock()
+	b := c.pages[key]
+	if b == nil || time.Since(b.touched) > pageExpiry {
+		delete(c.pages, key)
+		c.pagesMu.Unlock()
+		return false
+	}
+
+	current, total := b.position()
+	end := b.next + b.size
+	if end > len(b.lines) {
+		end = len(b.lines)
+	}
+	lines := b.lines[b.next:end]
+	b.next = end
+	b.touched = time.Now()
+	more := b.next < len(b.lines)
+	if !more {
+		delete(c.pages, key)
+	}
+	c.pagesMu.Unlock()
+
+	for _, line := range lines {
+		c.privmsg(nick, line)
+	}
+	if more {
+		c.privmsg(nick, fmt.Sprintf("Type \x02!more\x02 for the next page (%d of %d), or !stop", current+1, total))
+	}
+	return true
+}
+
+// dropPages discards nick's unread pages, returning how many lines there were
+func (c *Client) dropPages(nick string) int {
+	key := strings.ToLower(nick)
+
+	c.pagesMu.Lock()
+	defer c.pagesMu.Unlock()
+
+	b := c.pages[key]
+	if b == nil {
+		return 0
+	}
+	delete(c.pages, key)
+	return len(b.lines) - b.next
+}
+
+func (c *Client) cmdMore(nick, hostmask, message string) {
+	if !c.sendPage(nick) {
+		c.privmsg(nick, "Nothing more to show")
+	}
+}
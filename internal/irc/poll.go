@@ -0,0 +1,99 @@
+package irc
+
+import (
+	"log"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+// pollSource is the log source used for changes found by LINKS polling
+const pollSource = "LINKS poll"
+
+// linksTimeout is how long a LINKS reply may take before it is given up on
+const linksTimeout = 60 * time.Second
+
+// startPolling sends LINKS every PollInterval seconds for as long as the
+// client is running
+func (c *Client) startPolling() {
+	interval := time.Duration(c.cfg.PollInterval) * time.Second
+	log.Printf("Polling LINKS every %s", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			c.mu.RLock()
+			ready, closed := c.ready, c.closed
+			c.mu.RUnlock()
+
+			if closed {
+				return
+			}
+			if ready {
+				c.pollLinks()
+			}
+		}
+	}()
+}
+
+// pollLinks requests LINKS without a reply target. It is skipped when a
+// LINKS request is already being collected.
+func (c *Client) pollLinks() {
+	c.linksMu.Lock()
+	if c.collectingLinks() {
+		c.linksMu.Unlock()
+		return
+	}
+	c.linksTree = routing.NewLinkTree()
+	c.linksRequests = nil
+	c.linksSent = time.Now()
+	c.linksMu.Unlock()
+
+	c.sendRaw("LINKS")
+}
+
+// collectingLinks reports whether a LINKS reply is being collected. One
+// that has not ended after linksTimeout (no 365 came back) is dropped so
+// polls and !links aren't stuck behind it. linksMu must be held.
+func (c *Client) collectingLinks() bool {
+	if c.linksTree == nil {
+		return false
+	}
+	if time.Since(c.linksSent) < linksTimeout {
+		return true
+	}
+	log.Printf("LINKS reply not finished after %s, dropping it", linksTimeout)
+	c.linksTree = nil
+	c.linksRequests = nil
+	return false
+}
+
+// recordLinks stores a completed LINKS tree as the latest snapshot and logs
+// any changes since the previous one. New misrouted and missing servers are
+// announced in the report channel, and the topology is exported if enabled.
+func (c *Client) recordLinks(tree *routing.LinkTree) {
+	c.linksMu.Lock()
+	prev := c.lastLinks
+	c.lastLinks = tree
+	c.lastLinksAt = time.Now()
+	at := c.lastLinksAt
+	c.linksMu.Unlock()
+
+	if tree.Len() > 0 {
+		c.recordSnapshotMetrics(tree, at)
+	}
+
+	c.announceSnapshot(tree)
+	c.exportTopology(tree)
+
+	if prev == nil || tree.Len() == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, change := range routing.DiffLinks(prev, tree) {
+		c.addEvent(change.Event(pollSource, now))
+	}
+}
@@ -0,0 +1,55 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/config"
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+func TestCollectingLinks(t *testing.T) {
+	c := &Client{}
+	if c.collectingLinks() {
+		t.Error("Expected no collection without a LINKS request")
+	}
+
+	c.linksTree = routing.NewLinkTree()
+	c.linksRequests = []linksRequest{{nick: "alice", mode: linksFull}}
+	c.linksSent = time.Now()
+	if !c.collectingLinks() {
+		t.Error("Expected a recent LINKS request to be collecting")
+	}
+
+	c.linksSent = time.Now().Add(-linksTimeout - time.Second)
+	if c.collectingLinks() {
+		t.Error("Expected a LINKS request with no end to time out")
+	}
+	if c.linksTree != nil || c.linksRequests != nil {
+		t.Errorf("Expected the timed out collection to be dropped, requests %v", c.linksRequests)
+	}
+}
+
+func TestLinksRequestsShareCollection(t *testing.T) {
+	c, err := NewClient(&config.Config{Servers: []string{"127.0.0.1:6667"}, Nick: "rnexus", DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	c.cmdLinks("alice", "alice!a@example.com", "!links", linksFull)
+	c.linksMu.Lock()
+	tree := c.linksTree
+	tree.Add("hub1.dal.net", "hub1.dal.net", 0, "Hub 1")
+	c.linksMu.Unlock()
+
+	// A second request while the reply is coming in must not restart it
+	c.cmdLinks("bob", "bob!b@example.com", "!whatif hub1", linksWhatIf)
+	c.linksMu.Lock()
+	defer c.linksMu.Unlock()
+	if c.linksTree != tree || tree.Len() != 1 {
+		t.Error("Expected the running collection to be kept")
+	}
+	if len(c.linksRequests) != 2 || c.linksRequests[1].nick != "bob" || c.linksRequests[1].args[0] != "hub1" {
+		t.Errorf("Expected both requests to be answered, got %+v", c.linksRequests)
+	}
+}
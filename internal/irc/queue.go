@@ -0,0 +1,335 @@
+package irc
+
+import (
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Flood control defaults. The global bucket keeps the connection under the
+// server's flood limit; per-target buckets share it fairly between users.
+const (
+	defaultSendBurst = 5   // Lines sent back to back before throttling
+	defaultSendRate  = 2.0 // Lines per second once the burst is used up
+	targetBurst      = 3
+	targetRate       = 1.0
+
+	// Quit waits this long for queued replies to go out
+	drainTimeout = 5 * time.Second
+	drainPoll    = 50 * time.Millisecond
+)
+
+// outLine is a line waiting in the send queue
+type outLine struct {
+	target string // Reply target, empty for protocol lines
+	raw    string // Sent as is when set
+	cmd    string
+	params []string
+}
+
+// tokenBucket allows burst lines at once, refilling at rate per second
+type tokenBucket struct {
+	tokens float64
+	burst  float64
+	rate   float64
+	last   time.Time
+}
+
+func newTokenBucket(burst int, rate float64, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: float64(burst), burst: float64(burst), rate: rate, last: now}
+}
+
+// refill adds the tokens earned since the last refill
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// wait returns how long until a token is available. It is rounded up and
+// never 0 while a token is missing, as 0 means there is nothing to wait for.
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	wait := time.Duration(math.Ceil((1 - b.tokens) / b.rate * float64(time.Second)))
+	if wait < time.Nanosecond {
+		wait = time.Nanosecond
+	}
+	return wait
+}
+
+// sendQueue paces outgoing lines. Protocol lines (WHOIS, LINKS, OPER,
+// services) go ahead of replies, and replies are sent round robin between
+// targets so one long !logs doesn't hold up everyone else. PONGs are
+// answered by ircevent directly and never wait here.
+type sendQueue struct {
+	send func(outLine)
+	now  func() time.Time
+
+	targetBurst int
+	targetRate  float64
+
+	mu       sync.Mutex
+	priority []outLine
+	bulk     map[string][]outLine // Keyed by lowercased target
+	order    []string             // Targets with queued lines, round robin
+	global   *tokenBucket
+	targets  map[string]*tokenBucket
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{} // Closed when run returns
+	once sync.Once
+}
+
+func newSendQueue(burst int, rate float64, send func(outLine)) *sendQueue {
+	if burst <= 0 {
+		burst = defaultSendBurst
+	}
+	if rate <= 0 {
+		rate = defaultSendRate
+	}
+	now := time.Now
+	return &sendQueue{
+		send:        send,
+		now:         now,
+		targetBurst: targetBurst,
+		targetRate:  targetRate,
+		bulk:        make(map[string][]outLine),
+		global:      newTokenBucket(burst, rate, now()),
+		targets:     make(map[string]*tokenBucket),
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// run sends queued lines as the buckets allow until close is called
+func (q *sendQueue) run() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		line, ok, wait := q.next(q.now())
+		q.mu.Unlock()
+
+		if ok {
+			q.send(line)
+			continue
+		}
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-q.wake:
+		case <-expired:
+		case <-q.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// close stops run; anything still queued is dropped
+func (q *sendQueue) close() {
+	q.once.Do(func() { close(q.stop) })
+}
+
+// drain gives run up to timeout to send what is queued, then stops it once
+// the line in flight is written
+func (q *sendQueue) drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for q.pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPoll)
+	}
+	q.close()
+
+	select {
+	case <-q.done:
+	case <-time.After(time.Until(deadline) + drainPoll):
+	}
+}
+
+// pending returns the number of queued lines
+func (q *sendQueue) pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := len(q.priority)
+	for _, lines := range q.bulk {
+		n += len(lines)
+	}
+	return n
+}
+
+// signal wakes run for a newly queued line
+func (q *sendQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pushPriority queues a protocol line ahead of all replies
+func (q *sendQueue) pushPriority(line outLine) {
+	q.mu.Lock()
+	q.priority = append(q.priority, line)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// push queues a reply behind any others for the same target
+func (q *sendQueue) push(line outLine) {
+	key := strings.ToLower(line.target)
+
+	q.mu.Lock()
+	if len(q.bulk[key]) == 0 {
+		q.order = append(q.order, key)
+	}
+	q.bulk[key] = append(q.bulk[key], line)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// cancel drops the replies queued for target and returns how many there were
+func (q *sendQueue) cancel(target string) int {
+	key := strings.ToLower(target)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := len(q.bulk[key])
+	if n > 0 {
+		delete(q.bulk, key)
+		q.removeTarget(key)
+	}
+	return n
+}
+
+// clear drops everything queued, for when the connection is lost
+func (q *sendQueue) clear() {
+	q.mu.Lock()
+	q.priority = nil
+	q.bulk = make(map[string][]outLine)
+	q.order = nil
+	q.mu.Unlock()
+}
+
+// next takes the next line that may be sent now. If none may, it returns how
+// long to wait, or 0 if there is nothing queued. The caller holds q.mu.
+func (q *sendQueue) next(now time.Time) (outLine, bool, time.Duration) {
+	q.global.refill(now)
+	if len(q.priority) == 0 && len(q.order) == 0 {
+		q.pruneTargets(now)
+		return outLine{}, false, 0
+	}
+	if q.global.tokens < 1 {
+		return outLine{}, false, q.global.wait()
+	}
+
+	if len(q.priority) > 0 {
+		line := q.priority[0]
+		q.priority = q.priority[1:]
+		q.global.tokens--
+		return line, true, 0
+	}
+
+	var minWait time.Duration
+	for i, key := range q.order {
+		b := q.targetBucket(key, now)
+		if b.tokens < 1 {
+			if w := b.wait(); minWait == 0 || w < minWait {
+				minWait = w
+			}
+			continue
+		}
+
+		line := q.bulk[key][0]
+		q.bulk[key] = q.bulk[key][1:]
+		b.tokens--
+		q.global.tokens--
+
+		// Move this target to the back of the line
+		q.order = append(q.order[:i], q.order[i+1:]...)
+		if len(q.bulk[key]) > 0 {
+			q.order = append(q.order, key)
+		} else {
+			delete(q.bulk, key)
+		}
+		return line, true, 0
+	}
+	return outLine{}, false, minWait
+}
+
+// targetBucket returns the refilled bucket for a target
+func (q *sendQueue) targetBucket(key string, now time.Time) *tokenBucket {
+	b := q.targets[key]
+	if b == nil {
+		b = newTokenBucket(q.targetBurst, q.targetRate, now)
+		q.targets[key] = b
+	}
+	b.refill(now)
+	return b
+}
+
+// pruneTargets forgets buckets that have filled back up
+func (q *sendQueue) pruneTargets(now time.Time) {
+	for key, b := range q.targets {
+		b.refill(now)
+		if b.tokens >= b.burst {
+			delete(q.targets, key)
+		}
+	}
+}
+
+// removeTarget takes key out of the round robin order
+func (q *sendQueue) removeTarget(key string) {
+	for i, k := range q.order {
+		if k == key {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// privmsg queues a reply to target
+func (c *Client) privmsg(target, text string) {
+	c.queue.push(outLine{target: target, cmd: "PRIVMSG", params: []string{target, text}})
+}
+
+// send queues a protocol command ahead of replies
+func (c *Client) send(cmd string, params ...string) {
+	c.queue.pushPriority(outLine{cmd: cmd, params: params})
+}
+
+// sendRaw queues a raw protocol line ahead of replies
+func (c *Client) sendRaw(line string) {
+	c.queue.pushPriority(outLine{raw: line})
+}
+
+// writeLine sends a line from the queue to the server
+func (c *Client) writeLine(line outLine) {
+	var err error
+	if line.raw != "" {
+		err = c.conn.SendRaw(line.raw)
+	} else {
+		err = c.conn.Send(line.cmd, line.params...)
+	}
+	if err != nil {
+		log.Printf("Error sending to server: %v", err)
+	}
+}
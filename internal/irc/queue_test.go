@@ -0,0 +1,125 @@
+package irc
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendQueueOrder(t *testing.T) {
+	q := newSendQueue(10, 1, nil)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		q.push(outLine{target: "alice", cmd: "PRIVMSG", params: []string{"alice", "a"}})
+	}
+	q.push(outLine{target: "bob", cmd: "PRIVMSG", params: []string{"bob", "b"}})
+	q.pushPriority(outLine{raw: "WHOIS carol"})
+
+	var got []string
+	for {
+		line, ok, _ := q.next(now)
+		if !ok {
+			break
+		}
+		if line.raw != "" {
+			got = append(got, line.raw)
+		} else {
+			got = append(got, line.target)
+		}
+	}
+
+	// Protocol first, then replies round robin
+	want := []string{"WHOIS carol", "alice", "bob", "alice", "alice"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Line %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestSendQueueThrottle(t *testing.T) {
+	q := newSendQueue(2, 1, nil)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		q.pushPriority(outLine{raw: "LINKS"})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok, _ := q.next(now); !ok {
+			t.Fatalf("Line %d should be sent within the burst", i)
+		}
+	}
+	_, ok, wait := q.next(now)
+	if ok {
+		t.Fatalf("Third line should wait for a token")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("Expected a wait of up to 1s, got %v", wait)
+	}
+	if _, ok, _ := q.next(now.Add(wait)); !ok {
+		t.Errorf("Line should be sent after waiting")
+	}
+}
+
+func TestTokenBucketWaitRoundsUp(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(1, 2, now)
+	b.tokens = 1 - 1e-12
+	if wait := b.wait(); wait <= 0 {
+		t.Errorf("Expected a wait for an almost full token, got %v", wait)
+	}
+
+	// The queue must not look idle while a line is waiting for that token
+	q := newSendQueue(1, 2, nil)
+	q.pushPriority(outLine{raw: "LINKS"})
+	q.global = b
+	q.global.last = now
+	if _, ok, wait := q.next(now); ok || wait <= 0 {
+		t.Errorf("Expected the line to wait, got sent %v and wait %v", ok, wait)
+	}
+}
+
+func TestSendQueueCancel(t *testing.T) {
+	q := newSendQueue(10, 1, nil)
+	for i := 0; i < 5; i++ {
+		q.push(outLine{target: "Alice", cmd: "PRIVMSG", params: []string{"Alice", "x"}})
+	}
+	q.push(outLine{target: "bob", cmd: "PRIVMSG", params: []string{"bob", "y"}})
+
+	if n := q.cancel("alice"); n != 5 {
+		t.Errorf("Expected 5 lines cancelled, got %d", n)
+	}
+	line, ok, _ := q.next(time.Now())
+	if !ok || line.target != "bob" {
+		t.Errorf("Expected bob's line next, got %+v", line)
+	}
+	if _, ok, _ := q.next(time.Now()); ok {
+		t.Errorf("Queue should be empty")
+	}
+}
+
+func TestSendQueueDrain(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	q := newSendQueue(2, 20, func(line outLine) {
+		mu.Lock()
+		sent = append(sent, line.params[1])
+		mu.Unlock()
+	})
+	for _, text := range []string{"one", "two", "Shutting down"} {
+		q.push(outLine{target: "alice", cmd: "PRIVMSG", params: []string{"alice", text}})
+	}
+	go q.run()
+
+	q.drain(time.Second)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 3 || sent[2] != "Shutting down" {
+		t.Errorf("Expected every queued line to be sent, got %v", sent)
+	}
+}
@@ -0,0 +1,147 @@
+package irc
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/ergochat/irc-go/ircmsg"
+)
+
+// Reconnect timing
+const (
+	reconnectMin  = 5 * time.Second
+	reconnectMax  = 5 * time.Minute
+	stableAfter   = 2 * time.Minute // Connected this long resets the backoff
+	failoverAfter = 3               // Consecutive failures before trying the next server
+)
+
+// Run connects and keeps the client connected until Quit is called.
+// Dropped connections are retried with exponential backoff, moving on to
+// the next configured server after repeated failures.
+func (c *Client) Run() {
+	servers := c.cfg.ServerList()
+	current := 0
+	failures := 0       // Consecutive failures, for the backoff
+	serverFailures := 0 // Consecutive failures on the current server
+
+	for {
+		server := servers[current]
+		c.conn.Server = server
+
+		// Discard any disconnect left over from the previous connection
+		select {
+		case <-c.disconnected:
+		default:
+		}
+
+		log.Printf("Connecting to %s...", server)
+		err := c.conn.Connect()
+		if err != nil {
+			log.Printf("Failed to connect to %s: %s", server, describeConnectError(err))
+		} else {
+			<-c.disconnected
+			log.Printf("Disconnected from %s", server)
+		}
+		c.closeSocket()
+
+		c.mu.RLock()
+		closed, readyAt := c.closed, c.readyAt
+		c.mu.RUnlock()
+		if closed {
+			return
+		}
+
+		c.resetState()
+		c.metrics.reconnects.Inc()
+
+		if err == nil && !readyAt.IsZero() && time.Since(readyAt) >= stableAfter {
+			failures, serverFailures = 0, 0
+		} else {
+			failures++
+			serverFailures++
+		}
+
+		if serverFailures >= failoverAfter && len(servers) > 1 {
+			current = (current + 1) % len(servers)
+			serverFailures = 0
+			log.Printf("Giving up on %s for now, trying %s next", server, servers[current])
+		}
+
+		delay := backoff(failures)
+		log.Printf("Reconnecting in %s", delay.Round(time.Second))
+		time.Sleep(delay)
+	}
+}
+
+// backoff returns the delay before the next connection attempt after the
+// given number of consecutive failures, doubling from reconnectMin up to
+// reconnectMax with up to half of it taken off at random
+func backoff(failures int) time.Duration {
+	delay := reconnectMax
+	if failures < 10 {
+		delay = reconnectMin << failures
+		if delay > reconnectMax {
+			delay = reconnectMax
+		}
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// trackSocket wraps dial to keep each new socket so Run can close it.
+// ircevent only closes the socket from its own Loop, which Run replaces, so a
+// connection dropped by a ping timeout or write error would otherwise stay
+// open and the server could still see the old client.
+func (c *Client) trackSocket(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		socket, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c.socketMu.Lock()
+		c.socket = socket
+		c.socketMu.Unlock()
+		return socket, nil
+	}
+}
+
+// closeSocket closes the last connection's socket, if still open
+func (c *Client) closeSocket() {
+	c.socketMu.Lock()
+	defer c.socketMu.Unlock()
+	if c.socket != nil {
+		c.socket.Close()
+		c.socket = nil
+	}
+}
+
+func (c *Client) onDisconnect(e ircmsg.Message) {
+	select {
+	case c.disconnected <- struct{}{}:
+	default:
+	}
+}
+
+// resetState forgets everything tied to the previous connection. Oper status
+// is rechecked by WHOIS and admins log in again, since WATCH went with it.
+// Output still queued for the old connection is dropped.
+func (c *Client) resetState() {
+	c.metrics.connected.Set(0)
+
+	c.mu.Lock()
+	c.ready = false
+	c.readyAt = time.Time{}
+	c.opers = make(map[string]bool)
+	c.admins = make(map[string]*adminSession)
+	c.pendingWhois = make(map[string]*pendingCheck)
+	c.mu.Unlock()
+
+	c.queue.clear()
+
+	c.linksMu.Lock()
+	c.linksTree = nil
+	c.linksRequests = nil
+	c.linksMu.Unlock()
+}
@@ -0,0 +1,69 @@
+package irc
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/config"
+)
+
+// fakeServer accepts one connection on ln and registers the client: it
+// reads until USER and replies with RPL_WELCOME and ERR_NOMOTD
+func fakeServer(t *testing.T, ln net.Listener) net.Conn {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Client did not register: %v", err)
+		}
+		if strings.HasPrefix(line, "USER ") {
+			break
+		}
+	}
+	io.WriteString(conn, ":irc.test 001 rnexus :Welcome\r\n:irc.test 422 rnexus :MOTD File is missing\r\n")
+	return conn
+}
+
+func TestRunClosesDroppedSocket(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c, err := NewClient(&config.Config{
+		Servers:  []string{ln.Addr().String()},
+		Nick:     "rnexus",
+		Username: "rnexus",
+		DataDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	// PING straight away and give up on the next tick
+	c.conn.Timeout = 100 * time.Millisecond
+	c.conn.KeepAlive = 100 * time.Millisecond
+	go c.Run()
+	defer c.Quit("")
+
+	// The server never answers PING, so the client drops the connection
+	// and must close its end of it
+	server := fakeServer(t, ln)
+	defer server.Close()
+	_, err = io.Copy(io.Discard, server)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("The dropped connection's socket was left open")
+	}
+}
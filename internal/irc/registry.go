@@ -0,0 +1,325 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dalnet/rnexus/internal/auth"
+)
+
+// command declares a bot command. handleCommand checks the role and
+// arguments and writes the audit log before calling run.
+type command struct {
+	name    string // Including the "!"
+	aliases []string
+	args    []argSpec
+	summary string   // One line for !help
+	details []string // Extra lines for !help <command>
+	role    auth.Role
+	// auditName logs only the command name, for commands whose
+	// arguments may hold passwords in any position
+	auditName bool
+	// keepOutput leaves the user's queued output alone; any other
+	// command drops it
+	keepOutput bool
+	run        func(c *Client, nick, hostmask, message string)
+}
+
+// argSpec describes one command argument
+type argSpec struct {
+	name     string
+	optional bool
+	variadic bool // Takes the rest of the line
+	secret   bool // Masked in the audit log
+}
+
+// commands lists every bot command in !help order
+var commands []*command
+
+// commandIndex maps lowercased names and aliases to commands
+var commandIndex map[string]*command
+
+func init() {
+	commands = []*command{
+		{
+			name:    "!help",
+			args:    []argSpec{{name: "command", optional: true}},
+			summary: "lists commands, or shows details of one",
+			run:     (*Client).cmdHelp,
+		},
+		{
+			name:    "!summary",
+			summary: "displays a summary of currently linked/missing servers",
+			run: func(c *Client, nick, hostmask, message string) {
+				c.cmdLinks(nick, hostmask, message, linksSummary)
+			},
+		},
+		{
+			name:    "!links",
+			summary: "shows all currently connected servers, compared to the routing map",
+			run: func(c *Client, nick, hostmask, message string) {
+				c.cmdLinks(nick, hostmask, message, linksFull)
+			},
+		},
+		{
+			name:    "!compliance",
+			summary: "shows which linked servers are on their primary, secondary or tertiary hub, and which are misrouted",
+			run: func(c *Client, nick, hostmask, message string) {
+				c.cmdLinks(nick, hostmask, message, linksCompliance)
+			},
+		},
+		{
+			name:    "!path",
+			args:    []argSpec{{name: "server"}, {name: "server"}},
+			summary: "shows the hop-by-hop path between two servers, now and on their primary hubs",
+			run: func(c *Client, nick, hostmask, message string) {
+				c.cmdLinks(nick, hostmask, message, linksPath)
+			},
+		},
+		{
+			name:    "!whatif",
+			args:    []argSpec{{name: "hub"}},
+			summary: "shows which servers would be split off if a hub went down, and where they should relink",
+			run: func(c *Client, nick, hostmask, message string) {
+				c.cmdLinks(nick, hostmask, message, linksWhatIf)
+			},
+		},
+		{
+			name:    "!fixplan",
+			summary: "suggests the SQUIT and CONNECT commands to fix misrouted and missing servers",
+			details: []string{
+				"The plan is only printed, never carried out",
+				"Hubs come first, and each server goes to its highest-priority hub that is linked",
+				"A misrouted server never goes to a hub behind it, and my own server is left alone",
+			},
+			run: func(c *Client, nick, hostmask, message string) {
+				c.cmdLinks(nick, hostmask, message, linksFixPlan)
+			},
+		},
+		{
+			name:    "!map",
+			summary: "displays the most recent routing map",
+			run:     (*Client).cmdMap,
+		},
+		{
+			name:    "!mapdiff",
+			args:    []argSpec{{name: "v1", optional: true}, {name: "v2", optional: true}},
+			summary: "shows servers added, removed and reassigned between two versions of the routing map",
+			details: []string{
+				"Without versions, compares the latest map with the one before; with one, compares that version with the latest",
+			},
+			run: (*Client).cmdMapDiff,
+		},
+		{
+			name:    "!mapversions",
+			args:    []argSpec{{name: "server", optional: true}},
+			summary: "lists the saved versions of the routing map with who loaded them and when",
+			details: []string{"With a server, only lists the versions that changed its hubs"},
+			run:     (*Client).cmdMapVersions,
+		},
+		{
+			name:    "!logs",
+			args:    []argSpec{{name: "count", optional: true}, {name: "filters", optional: true, variadic: true}},
+			summary: "displays the last routing notices received (10 unless a count is given)",
+			details: []string{
+				"Filters: type:<type> and server:<name> only show notices of a given type and/or about a given server",
+				"log:<name> shows the notices logged by another filter in notice_filters instead",
+				"Anything else is matched against the notice text",
+			},
+			run: (*Client).cmdLogs,
+		},
+		{
+			name:    "!logsearch",
+			args:    []argSpec{{name: "text", variadic: true}},
+			summary: "search logs of routing notices for a given string (type:, server: and log: filters also work here)",
+			run:     (*Client).cmdLogSearch,
+		},
+		{
+			name:    "!uplinks",
+			args:    []argSpec{{name: "server", optional: true}},
+			summary: "shows the primary, secondary and tertiary hubs for the specified server",
+			details: []string{"Without a server, lists every server in the routing map with its hubs"},
+			run:     (*Client).cmdUplinks,
+		},
+		{
+			name:    "!motd",
+			summary: "displays the MOTD from the routing team",
+			run:     (*Client).cmdMotd,
+		},
+		{
+			name:    "!version",
+			summary: "displays bot version information",
+			run:     (*Client).cmdVersion,
+		},
+		{
+			name:       "!more",
+			summary:    "shows the next page of a long reply",
+			keepOutput: true,
+			run:        (*Client).cmdMore,
+		},
+		{
+			name:       "!stop",
+			summary:    "stops sending the rest of a long reply",
+			keepOutput: true,
+			run:        (*Client).cmdStop,
+		},
+		{
+			name:    "!login",
+			aliases: []string{"!su"},
+			args:    []argSpec{{name: "account", optional: true}, {name: "password", secret: true}},
+			summary: "logs in to an admin account",
+			details: []string{
+				"The account can only be left out when logging in with admin_pass, before any accounts exist",
+				"With auth_mode: nickserv, no arguments are needed",
+			},
+			auditName: true,
+			run:       (*Client).cmdLogin,
+		},
+		{
+			name:    "!logout",
+			summary: "logs out of your admin account",
+			run:     (*Client).cmdLogout,
+		},
+		{
+			name:    "!passwd",
+			args:    []argSpec{{name: "new password", secret: true}},
+			summary: "change your own password",
+			role:    auth.RoleViewer,
+			run:     (*Client).cmdPasswd,
+		},
+		{
+			name:    "!set",
+			args:    []argSpec{{name: "setting"}, {name: "value", variadic: true}},
+			summary: "changes a setting; only motd for now",
+			details: []string{"!set motd <message> - sets the MOTD shown by !motd and !links"},
+			role:    auth.RoleRoutingAdmin,
+			run:     (*Client).cmdSet,
+		},
+		{
+			name:    "!reload",
+			summary: "reload a fresh copy of the current routing map",
+			details: []string{
+				"Shows any problems found in the map. A map with errors is not loaded and the previous one stays in use",
+			},
+			role: auth.RoleRoutingAdmin,
+			run:  (*Client).cmdReload,
+		},
+		{
+			name:    "!nick",
+			args:    []argSpec{{name: "newnick"}},
+			summary: "if you need to change my nick",
+			role:    auth.RoleBotOwner,
+			run:     (*Client).cmdNick,
+		},
+		{
+			name:    "!restart",
+			summary: "restarts the bot",
+			role:    auth.RoleBotOwner,
+			run:     (*Client).cmdRestart,
+		},
+		{
+			name:    "!shutdown",
+			summary: "shuts the bot down",
+			role:    auth.RoleBotOwner,
+			run:     (*Client).cmdShutdown,
+		},
+		{
+			name:    "!account",
+			args:    []argSpec{{name: "action"}, {name: "arguments", optional: true, variadic: true}},
+			summary: "manages admin accounts",
+			details: []string{
+				"!account list",
+				"!account add <name> <role> <password>",
+				"!account del <name>",
+				"!account passwd <name> <password>",
+				"!account role <name> <role>",
+				"Roles are viewer, routing-admin and bot-owner",
+			},
+			role:      auth.RoleBotOwner,
+			auditName: true,
+			run:       (*Client).cmdAccount,
+		},
+	}
+
+	commandIndex = make(map[string]*command)
+	for _, cmd := range commands {
+		commandIndex[cmd.name] = cmd
+		for _, alias := range cmd.aliases {
+			commandIndex[alias] = cmd
+		}
+	}
+}
+
+// lookupCommand finds a command by name or alias, with or without the "!"
+func lookupCommand(name string) *command {
+	name = strings.ToLower(name)
+	if !strings.HasPrefix(name, "!") {
+		name = "!" + name
+	}
+	return commandIndex[name]
+}
+
+// usage returns the command with its arguments, e.g. "!logs [count] [filters...]"
+func (cmd *command) usage() string {
+	parts := []string{cmd.name}
+	for _, arg := range cmd.args {
+		name := arg.name
+		if arg.variadic {
+			name += "..."
+		}
+		if arg.optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// checkArgs reports whether n arguments satisfy the command's arguments
+func (cmd *command) checkArgs(n int) bool {
+	required, max := 0, 0
+	for _, arg := range cmd.args {
+		if !arg.optional {
+			required++
+		}
+		if arg.variadic {
+			max = -1
+		} else if max >= 0 {
+			max++
+		}
+	}
+	return n >= required && (max < 0 || n <= max)
+}
+
+// auditText returns the message as it goes in the stats log, with secret
+// arguments masked
+func (cmd *command) auditText(message string) string {
+	if cmd.auditName {
+		return cmd.name
+	}
+
+	fields := strings.Fields(message)
+	for i, arg := range cmd.args {
+		if arg.secret && i+1 < len(fields) {
+			fields[i+1] = "***"
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// dispatch runs a command after checking the sender's role and arguments
+func (c *Client) dispatch(cmd *command, nick, hostmask, message string) {
+	if cmd.role != "" && !c.checkRole(nick, hostmask, cmd.role, fmt.Sprintf("Sorry, only my admins can use %s", cmd.name), "use "+cmd.name) {
+		return
+	}
+
+	if !cmd.checkArgs(len(strings.Fields(message)) - 1) {
+		c.privmsg(nick, "Usage: "+cmd.usage())
+		return
+	}
+
+	c.logCommand(hostmask, cmd.auditText(message))
+	c.metrics.commands.Inc(cmd.name)
+	cmd.run(c, nick, hostmask, message)
+}
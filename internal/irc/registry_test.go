@@ -0,0 +1,67 @@
+package irc
+
+import "testing"
+
+func TestCommandRegistry(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, cmd := range commands {
+		if cmd.summary == "" || cmd.run == nil {
+			t.Errorf("%s is missing a summary or handler", cmd.name)
+		}
+		for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+			if seen[name] {
+				t.Errorf("%s is registered twice", name)
+			}
+			seen[name] = true
+		}
+	}
+
+	if cmd := lookupCommand("SU"); cmd == nil || cmd.name != "!login" {
+		t.Errorf("Expected su to find !login, got %+v", cmd)
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	logs := lookupCommand("!logs")
+	if got := logs.usage(); got != "!logs [count] [filters...]" {
+		t.Errorf("Unexpected usage %q", got)
+	}
+	for _, n := range []int{0, 1, 5} {
+		if !logs.checkArgs(n) {
+			t.Errorf("!logs should accept %d arguments", n)
+		}
+	}
+
+	nick := lookupCommand("!nick")
+	if nick.checkArgs(0) || !nick.checkArgs(1) || nick.checkArgs(2) {
+		t.Errorf("!nick should take exactly one argument")
+	}
+}
+
+func TestCommandAuditText(t *testing.T) {
+	if got := lookupCommand("!passwd").auditText("!passwd hunter2"); got != "!passwd ***" {
+		t.Errorf("Password not masked: %q", got)
+	}
+	if got := lookupCommand("!login").auditText("!login alice hunter2"); got != "!login" {
+		t.Errorf("Login arguments logged: %q", got)
+	}
+	if got := lookupCommand("!logs").auditText("!logs 20 type:split"); got != "!logs 20 type:split" {
+		t.Errorf("Unexpected audit text %q", got)
+	}
+}
+
+func TestAfterFields(t *testing.T) {
+	for _, tt := range []struct {
+		message string
+		want    string
+	}{
+		{"!set motd Hub maintenance  tonight", "Hub maintenance  tonight"},
+		{"!set motd\tfoo", "foo"},
+		{"!set\tmotd foo bar ", "foo bar"},
+		{"!set motd", ""},
+	} {
+		if got := afterFields(tt.message, 2); got != tt.want {
+			t.Errorf("afterFields(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
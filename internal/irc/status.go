@@ -0,0 +1,37 @@
+package irc
+
+import (
+	"time"
+
+	"github.com/dalnet/rnexus/internal/routing"
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+// LastLinks returns the latest complete LINKS snapshot and when it was
+// taken, or nil if there is none yet
+func (c *Client) LastLinks() (*routing.LinkTree, time.Time) {
+	c.linksMu.Lock()
+	defer c.linksMu.Unlock()
+	return c.lastLinks, c.lastLinksAt
+}
+
+// RoutingMap returns the routing map in use
+func (c *Client) RoutingMap() *routing.Map {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.routingMap
+}
+
+// NoticeLogs returns the names of the configured notice filters
+func (c *Client) NoticeLogs() []string {
+	var names []string
+	for _, f := range c.noticeFilters {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// Store returns the log, stats and MOTD storage
+func (c *Client) Store() storage.Store {
+	return c.store
+}
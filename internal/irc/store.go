@@ -0,0 +1,78 @@
+package irc
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+// errStoreClosed is returned for store calls made after Quit
+var errStoreClosed = errors.New("storage is closed")
+
+// closableStore guards a store against use after Close. ircevent can still
+// run NOTICE and LINKS callbacks while Quit shuts down, so Close waits for
+// calls in progress and later calls fail instead of reaching a closed
+// backend.
+type closableStore struct {
+	mu     sync.RWMutex
+	closed bool
+	store  storage.Store
+}
+
+// do runs fn with the store unless it has been closed
+func (s *closableStore) do(fn func(storage.Store) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errStoreClosed
+	}
+	return fn(s.store)
+}
+
+func (s *closableStore) AppendLog(r storage.LogRecord) error {
+	return s.do(func(st storage.Store) error { return st.AppendLog(r) })
+}
+
+func (s *closableStore) Logs(q storage.Query) (logs []storage.LogRecord, err error) {
+	err = s.do(func(st storage.Store) error {
+		logs, err = st.Logs(q)
+		return err
+	})
+	return logs, err
+}
+
+func (s *closableStore) AppendStat(r storage.StatRecord) error {
+	return s.do(func(st storage.Store) error { return st.AppendStat(r) })
+}
+
+func (s *closableStore) Stats(q storage.Query) (stats []storage.StatRecord, err error) {
+	err = s.do(func(st storage.Store) error {
+		stats, err = st.Stats(q)
+		return err
+	})
+	return stats, err
+}
+
+func (s *closableStore) MOTD() (motd *storage.MOTD, err error) {
+	err = s.do(func(st storage.Store) error {
+		motd, err = st.MOTD()
+		return err
+	})
+	return motd, err
+}
+
+func (s *closableStore) SetMOTD(m *storage.MOTD) error {
+	return s.do(func(st storage.Store) error { return st.SetMOTD(m) })
+}
+
+// Close waits for calls in progress, then closes the store
+func (s *closableStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.store.Close()
+}
@@ -0,0 +1,35 @@
+package irc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+func TestClosableStore(t *testing.T) {
+	store, err := storage.OpenBolt(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &closableStore{store: store}
+
+	if err := s.AppendLog(storage.LogRecord{Time: time.Now(), Server: "hub1", Text: "Link with leaf1 established"}); err != nil {
+		t.Fatalf("AppendLog failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A callback still running after Quit must not reach the closed database
+	if err := s.AppendLog(storage.LogRecord{Time: time.Now(), Text: "late"}); !errors.Is(err, errStoreClosed) {
+		t.Errorf("Expected errStoreClosed, got %v", err)
+	}
+	if _, err := s.Logs(storage.Query{}); !errors.Is(err, errStoreClosed) {
+		t.Errorf("Expected errStoreClosed, got %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Expected a second Close to do nothing, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/config"
+	"github.com/ergochat/irc-go/ircevent"
+	"github.com/ergochat/irc-go/ircmsg"
+)
+
+// SASL mechanisms accepted in sasl_mech
+const (
+	saslPlain    = "PLAIN"
+	saslExternal = "EXTERNAL"
+)
+
+// newTLSConfig builds the TLS configuration from the config file settings
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.TLSServerName,
+		InsecureSkipVerify: cfg.TLSInsecure,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if cfg.TLSCA != "" {
+		pem, err := os.ReadFile(cfg.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls_ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls_ca %s", cfg.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLSCert != "" {
+		keyFile := cfg.TLSKey
+		if keyFile == "" {
+			// Certificate and key in the same PEM file
+			keyFile = cfg.TLSCert
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls_cert: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// configureSecurity applies the TLS and SASL settings to conn. TLS and
+// SASL EXTERNAL are done by the dialer rather than by ircevent, which only
+// knows SASL PLAIN and keeps the first server's name for every TLS
+// handshake after it.
+func configureSecurity(conn *ircevent.Connection, cfg *config.Config) error {
+	if cfg.TLSCert != "" && !cfg.TLS {
+		return fmt.Errorf("tls_cert is set but tls is off")
+	}
+
+	d := &dialer{}
+	conn.DialContext = d.dial
+	if cfg.TLS {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return err
+		}
+		d.tlsConfig = tlsConfig
+	}
+
+	switch mech := strings.ToUpper(cfg.SASLMech); mech {
+	case "":
+		return nil
+	case saslPlain:
+		if cfg.SASLPassword == "" {
+			return fmt.Errorf("sasl_mech %s needs sasl_password", saslPlain)
+		}
+		conn.SASLLogin = cfg.SASLLogin
+		if conn.SASLLogin == "" {
+			conn.SASLLogin = cfg.Nick
+		}
+		conn.SASLPassword = cfg.SASLPassword
+		conn.SASLMech = mech
+	case saslExternal:
+		if cfg.TLSCert == "" {
+			return fmt.Errorf("sasl_mech %s needs a client certificate in tls_cert", saslExternal)
+		}
+		d.saslExternal = true
+		return nil
+	default:
+		return fmt.Errorf("unknown sasl_mech %q, expected %s or %s", cfg.SASLMech, saslPlain, saslExternal)
+	}
+	conn.UseSASL = true
+	return nil
+}
+
+// describeConnectError explains connection failures caused by TLS
+// verification, which retrying the same settings won't fix
+func describeConnectError(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var recordHeader tls.RecordHeaderError
+
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Sprintf("TLS certificate is not signed by a trusted CA (set tls_ca to the network's CA bundle): %v", err)
+	case errors.As(err, &hostname):
+		return fmt.Sprintf("TLS certificate does not match the server name (set tls_server_name): %v", err)
+	case errors.As(err, &invalid):
+		return fmt.Sprintf("TLS certificate is invalid: %v", err)
+	case errors.As(err, &recordHeader):
+		return fmt.Sprintf("TLS handshake failed, is this a plaintext port?: %v", err)
+	}
+	return err.Error()
+}
+
+// dialer opens connections for ircevent: TCP, then TLS and SASL EXTERNAL
+// when configured
+type dialer struct {
+	tlsConfig    *tls.Config // nil for plaintext
+	saslExternal bool
+}
+
+// dial connects to addr. The TLS server name is taken from addr unless
+// tls_server_name is set, so it follows failover to another server.
+func (d *dialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	socket, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+	if err != nil || d.tlsConfig == nil {
+		return socket, err
+	}
+
+	tlsConfig := d.tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		tlsConfig.ServerName = host
+	}
+	tlsSocket := tls.Client(socket, tlsConfig)
+	if err := tlsSocket.HandshakeContext(ctx); err != nil {
+		socket.Close()
+		return nil, err
+	}
+	if !d.saslExternal {
+		return tlsSocket, nil
+	}
+
+	conn, err := authenticateExternal(ctx, tlsSocket)
+	if err != nil {
+		tlsSocket.Close()
+		return nil, fmt.Errorf("SASL %s failed: %w", saslExternal, err)
+	}
+	return conn, nil
+}
+
+// authenticateExternal logs in with SASL EXTERNAL (the client certificate)
+// before ircevent sends NICK and USER. CAP REQ holds registration until
+// the CAP END sent here.
+func authenticateExternal(ctx context.Context, socket net.Conn) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		socket.SetDeadline(deadline)
+		defer socket.SetDeadline(time.Time{})
+	}
+
+	reader := bufio.NewReader(socket)
+	send := func(line string) error {
+		_, err := io.WriteString(socket, line+"\r\n")
+		return err
+	}
+	if err := send("CAP REQ :sasl"); err != nil {
+		return nil, err
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		msg, err := ircmsg.ParseLine(line)
+		if err != nil {
+			continue
+		}
+		last := ""
+		if len(msg.Params) > 0 {
+			last = msg.Params[len(msg.Params)-1]
+		}
+
+		switch msg.Command {
+		case "PING":
+			err = send("PONG :" + last)
+		case "CAP":
+			if len(msg.Params) < 2 {
+				continue
+			}
+			switch msg.Params[1] {
+			case "ACK":
+				err = send("AUTHENTICATE " + saslExternal)
+			case "NAK":
+				return nil, errors.New("server does not support SASL")
+			}
+		case "AUTHENTICATE":
+			if last == "+" {
+				err = send("AUTHENTICATE +")
+			}
+		case "903", "907": // RPL_SASLSUCCESS, ERR_SASLALREADY
+			if err := send("CAP END"); err != nil {
+				return nil, err
+			}
+			// Hand over anything read past the reply along with the socket
+			return &bufferedConn{Conn: socket, reader: reader}, nil
+		case "902", "904", "905", "906", "908":
+			return nil, errors.New(last)
+		case "ERROR":
+			return nil, fmt.Errorf("server closed the connection: %s", last)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// bufferedConn reads through a bufio.Reader that may already hold data
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,229 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/config"
+	"github.com/ergochat/irc-go/ircevent"
+)
+
+// testCA issues certificates for the TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "rnexus test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate for the given DNS name or IP address, as a
+// tls.Certificate and as PEM (certificate followed by key)
+func (ca *testCA) issue(t *testing.T, name string) (tls.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{name}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})...)
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, data
+}
+
+// listenTLS starts a TLS listener on 127.0.0.1 that asks for a client
+// certificate signed by ca
+func listenTLS(t *testing.T, ca *testCA, cert tls.Certificate) net.Listener {
+	t.Helper()
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+// testTLSConfig writes ca and a client certificate to a temporary directory
+// and returns a config using them
+func testTLSConfig(t *testing.T, ca *testCA) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	_, clientPEM := ca.issue(t, "rnexus")
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "rnexus.pem")
+	if err := os.WriteFile(caFile, ca.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, clientPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &config.Config{Nick: "rnexus", TLS: true, TLSCA: caFile, TLSCert: certFile}
+}
+
+func TestSASLExternal(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, _ := ca.issue(t, "127.0.0.1")
+	ln := listenTLS(t, ca, serverCert)
+
+	cfg := testTLSConfig(t, ca)
+	cfg.SASLMech = "external"
+	conn := &ircevent.Connection{Server: ln.Addr().String(), Nick: "rnexus", Timeout: 5 * time.Second}
+	if err := configureSecurity(conn, cfg); err != nil {
+		t.Fatalf("configureSecurity failed: %v", err)
+	}
+
+	// A server that insists on SASL EXTERNAL before registration
+	done := make(chan error, 1)
+	go func() {
+		server, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer server.Close()
+		server.SetDeadline(time.Now().Add(5 * time.Second))
+		reader := bufio.NewReader(server)
+		authenticated := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				done <- err
+				return
+			}
+			switch line = strings.TrimSpace(line); {
+			case line == "CAP REQ :sasl":
+				io.WriteString(server, ":irc.test CAP * ACK :sasl\r\n")
+			case line == "AUTHENTICATE EXTERNAL":
+				if len(server.(*tls.Conn).ConnectionState().PeerCertificates) == 0 {
+					io.WriteString(server, ":irc.test 904 * :No client certificate\r\n")
+					continue
+				}
+				io.WriteString(server, "AUTHENTICATE +\r\n")
+			case line == "AUTHENTICATE +":
+				authenticated = true
+				io.WriteString(server, ":irc.test 900 * rnexus!rnexus@host rnexus :You are now logged in\r\n"+
+					":irc.test 903 * :SASL authentication successful\r\n")
+			case strings.HasPrefix(line, "USER "):
+				if !authenticated {
+					io.WriteString(server, "ERROR :Registered before SASL\r\n")
+					continue
+				}
+				io.WriteString(server, ":irc.test 001 rnexus :Welcome\r\n:irc.test 422 rnexus :MOTD File is missing\r\n")
+			case strings.HasPrefix(line, "QUIT"):
+				done <- nil
+				return
+			}
+		}
+	}()
+
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	conn.Quit()
+	if err := <-done; err != nil {
+		t.Errorf("Server: %v", err)
+	}
+}
+
+func TestTLSServerNamePerServer(t *testing.T) {
+	ca := newTestCA(t)
+	ipCert, _ := ca.issue(t, "127.0.0.1")
+	nameCert, _ := ca.issue(t, "localhost")
+	first := listenTLS(t, ca, ipCert)
+	second := listenTLS(t, ca, nameCert)
+	for _, ln := range []net.Listener{first, second} {
+		go func(ln net.Listener) {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}
+		}(ln)
+	}
+
+	conn := &ircevent.Connection{}
+	if err := configureSecurity(conn, testTLSConfig(t, ca)); err != nil {
+		t.Fatalf("configureSecurity failed: %v", err)
+	}
+
+	// Failing over from one server to another checks each one's own name
+	_, port, _ := net.SplitHostPort(second.Addr().String())
+	for _, addr := range []string{first.Addr().String(), net.JoinHostPort("localhost", port)} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		socket, err := conn.DialContext(ctx, "tcp", addr)
+		cancel()
+		if err != nil {
+			t.Fatalf("Dial %s failed: %v", addr, err)
+		}
+		socket.Close()
+	}
+}
@@ -0,0 +1,170 @@
+// Package metrics keeps counters and gauges and writes them in the
+// Prometheus text exposition format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric kinds
+const (
+	kindCounter = "counter"
+	kindGauge   = "gauge"
+)
+
+// Registry holds metrics in the order they were registered
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// metric is a family of samples sharing a name and label names
+type metric struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu      sync.Mutex
+	samples map[string]*sample // Keyed by label values joined with \xff
+}
+
+type sample struct {
+	labels []string
+	value  float64
+}
+
+// Counter only goes up
+type Counter struct{ m *metric }
+
+// Gauge can be set to any value
+type Gauge struct{ m *metric }
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, kindCounter, labels)}
+}
+
+// Gauge registers a gauge with the given label names
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, kindGauge, labels)}
+}
+
+func (r *Registry) register(name, help, kind string, labels []string) *metric {
+	m := &metric{name: name, help: help, kind: kind, labels: labels, samples: make(map[string]*sample)}
+	r.mu.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mu.Unlock()
+	return m
+}
+
+// Inc adds one to the counter for the label values
+func (c *Counter) Inc(labels ...string) {
+	c.m.update(labels, func(v float64) float64 { return v + 1 })
+}
+
+// Add adds a positive amount to the counter for the label values
+func (c *Counter) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.m.update(labels, func(v float64) float64 { return v + delta })
+}
+
+// Set sets the gauge for the label values
+func (g *Gauge) Set(value float64, labels ...string) {
+	g.m.update(labels, func(float64) float64 { return value })
+}
+
+func (m *metric) update(labels []string, fn func(float64) float64) {
+	if len(labels) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d labels, got %d", m.name, len(m.labels), len(labels)))
+	}
+	key := strings.Join(labels, "\xff")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.samples[key]
+	if !ok {
+		s = &sample{labels: append([]string(nil), labels...)}
+		m.samples[key] = s
+	}
+	s.value = fn(s.value)
+}
+
+// WriteText writes every metric in the Prometheus text format. A metric
+// without labels is written as 0 until it is first updated.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]*metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", m.name, m.kind)
+
+		m.mu.Lock()
+		samples := make([]*sample, 0, len(m.samples))
+		for _, s := range m.samples {
+			samples = append(samples, &sample{labels: s.labels, value: s.value})
+		}
+		m.mu.Unlock()
+
+		if len(samples) == 0 && len(m.labels) == 0 {
+			samples = append(samples, &sample{})
+		}
+		sort.Slice(samples, func(i, j int) bool {
+			return strings.Join(samples[i].labels, "\xff") < strings.Join(samples[j].labels, "\xff")
+		})
+
+		for _, s := range samples {
+			b.WriteString(m.name)
+			if len(m.labels) > 0 {
+				b.WriteString("{")
+				for i, name := range m.labels {
+					if i > 0 {
+						b.WriteString(",")
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", name, escapeLabel(s.labels[i]))
+				}
+				b.WriteString("}")
+			}
+			fmt.Fprintf(&b, " %s\n", formatValue(s.value))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
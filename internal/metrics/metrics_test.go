@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	linked := r.Gauge("rnexus_servers_linked", "Servers in the last LINKS")
+	commands := r.Counter("rnexus_commands_total", "Commands handled", "command")
+	r.Counter("rnexus_whois_checks_total", "WHOIS checks sent")
+
+	linked.Set(42)
+	commands.Inc("!links")
+	commands.Inc("!links")
+	commands.Add(0.5, `say "hi"`)
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP rnexus_servers_linked Servers in the last LINKS
+# TYPE rnexus_servers_linked gauge
+rnexus_servers_linked 42
+# HELP rnexus_commands_total Commands handled
+# TYPE rnexus_commands_total counter
+rnexus_commands_total{command="!links"} 2
+rnexus_commands_total{command="say \"hi\""} 0.5
+# HELP rnexus_whois_checks_total WHOIS checks sent
+# TYPE rnexus_whois_checks_total counter
+rnexus_whois_checks_total 0
+`
+	if b.String() != want {
+		t.Errorf("Unexpected output:\n%s", b.String())
+	}
+}
@@ -0,0 +1,140 @@
+package routing
+
+import (
+	"sort"
+	"strings"
+)
+
+// Placement classifies where a linked server sits relative to its map assignments
+type Placement string
+
+const (
+	OnPrimary   Placement = "primary"
+	OnSecondary Placement = "secondary"
+	OnTertiary  Placement = "tertiary"
+	Misrouted   Placement = "misrouted"
+	Unmapped    Placement = "unmapped" // Linked but has no assignment in the map
+)
+
+// placementRanks maps a hub's index in the assignment list to a placement
+var placementRanks = []Placement{OnPrimary, OnSecondary, OnTertiary}
+
+// ComplianceEntry is the result for a single linked server
+type ComplianceEntry struct {
+	Server    string // Short server name
+	Hub       string // Short name of the hub it is linked to
+	Placement Placement
+	Expected  []string // Hub assignments from the map
+}
+
+// ComplianceReport holds the compliance of every linked server
+type ComplianceReport struct {
+	Entries []ComplianceEntry
+}
+
+// CheckCompliance compares the live LINKS tree against the routing map.
+//
+// LINKS is relative to the server we are connected to, so a hub can show up
+// as a child of its own leaf. A server is therefore matched against every
+// server it is directly linked to, not only its LinkEntry.Hub.
+func CheckCompliance(tree *LinkTree, rmap *Map) *ComplianceReport {
+	neighbours := tree.adjacency()
+
+	report := &ComplianceReport{}
+	for _, name := range tree.order {
+		entry := tree.entries[name]
+		server := shortName(entry.Server)
+
+		result := ComplianceEntry{
+			Server: server,
+			Hub:    shortName(entry.Hub),
+		}
+
+		hubs := rmap.assignments(server)
+		result.Expected = hubs
+
+		if len(hubs) == 0 {
+			result.Placement = Unmapped
+			report.Entries = append(report.Entries, result)
+			continue
+		}
+
+		result.Placement = Misrouted
+		linked := neighbours[strings.ToLower(server)]
+		for i, hub := range hubs {
+			if containsFold(linked, hub) {
+				result.Hub = hub
+				if i < len(placementRanks) {
+					result.Placement = placementRanks[i]
+				} else {
+					result.Placement = OnTertiary
+				}
+				break
+			}
+		}
+
+		report.Entries = append(report.Entries, result)
+	}
+
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		return report.Entries[i].Server < report.Entries[j].Server
+	})
+
+	return report
+}
+
+// Count returns the number of servers with the given placement
+func (r *ComplianceReport) Count(p Placement) int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Placement == p {
+			n++
+		}
+	}
+	return n
+}
+
+// Filter returns the entries with the given placement
+func (r *ComplianceReport) Filter(p Placement) []ComplianceEntry {
+	var result []ComplianceEntry
+	for _, e := range r.Entries {
+		if e.Placement == p {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// adjacency returns every server's directly linked servers, as short,
+// lowercased names
+func (t *LinkTree) adjacency() map[string][]string {
+	neighbours := make(map[string][]string)
+	for _, entry := range t.entries {
+		if entry.Server == entry.Hub {
+			continue
+		}
+		server := strings.ToLower(shortName(entry.Server))
+		hub := strings.ToLower(shortName(entry.Hub))
+		neighbours[server] = append(neighbours[server], hub)
+		neighbours[hub] = append(neighbours[hub], server)
+	}
+	return neighbours
+}
+
+// assignments returns a server's hubs from the map in priority order
+func (m *Map) assignments(server string) []string {
+	if entry := m.Entry(server); entry != nil {
+		return entry.Hubs
+	}
+	return m.Servers[server]
+}
+
+func containsFold(list []string, s string) bool {
+	s = strings.ToLower(shortName(s))
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
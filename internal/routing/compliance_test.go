@@ -0,0 +1,53 @@
+package routing
+
+import "testing"
+
+func TestCheckCompliance(t *testing.T) {
+	// Viewed from leaf1, so hub1 appears below its own leaf
+	tree := NewLinkTree()
+	tree.Add("leaf1.dal.net", "leaf1.dal.net", 0, "Leaf 1")
+	tree.Add("hub1.dal.net", "leaf1.dal.net", 1, "Hub 1")
+	tree.Add("hub2.dal.net", "hub1.dal.net", 2, "Hub 2")
+	tree.Add("leaf2.dal.net", "hub2.dal.net", 3, "Leaf 2")
+	tree.Add("leaf3.dal.net", "hub2.dal.net", 3, "Leaf 3")
+	tree.Add("stray.dal.net", "hub2.dal.net", 3, "Stray")
+
+	rmap := &Map{
+		ServerList: []string{"hub1", "hub2", "leaf1", "leaf2", "leaf3"},
+		Servers: map[string][]string{
+			"hub1":  {"hub2"},
+			"hub2":  {"hub1"},
+			"leaf1": {"hub1", "hub2"},
+			"leaf2": {"hub1", "hub2"},
+			"leaf3": {"hub1", "hub3"},
+		},
+	}
+
+	report := CheckCompliance(tree, rmap)
+
+	expected := map[string]Placement{
+		"hub1":  OnPrimary,
+		"hub2":  OnPrimary,
+		"leaf1": OnPrimary,
+		"leaf2": OnSecondary,
+		"leaf3": Misrouted,
+		"stray": Unmapped,
+	}
+
+	if len(report.Entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(report.Entries))
+	}
+	for _, e := range report.Entries {
+		if e.Placement != expected[e.Server] {
+			t.Errorf("%s: expected %s, got %s", e.Server, expected[e.Server], e.Placement)
+		}
+	}
+
+	if n := report.Count(OnPrimary); n != 3 {
+		t.Errorf("Expected 3 on primary, got %d", n)
+	}
+	misrouted := report.Filter(Misrouted)
+	if len(misrouted) != 1 || misrouted[0].Hub != "hub2" {
+		t.Errorf("Unexpected misrouted entries: %+v", misrouted)
+	}
+}
@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ChangeType describes how a server's link changed between two LINKS snapshots
+type ChangeType string
+
+const (
+	ServerAppeared    ChangeType = "appeared"
+	ServerDisappeared ChangeType = "disappeared"
+	HubChanged        ChangeType = "hub-changed"
+)
+
+// LinkChange is a single difference between two LINKS snapshots
+type LinkChange struct {
+	Type   ChangeType
+	Server string
+	OldHub string // Empty for ServerAppeared
+	NewHub string // Empty for ServerDisappeared
+}
+
+// String formats the change for the routing log
+func (c LinkChange) String() string {
+	switch c.Type {
+	case ServerAppeared:
+		return fmt.Sprintf("%s appeared, linked to %s", c.Server, c.NewHub)
+	case ServerDisappeared:
+		return fmt.Sprintf("%s disappeared, was linked to %s", c.Server, c.OldHub)
+	case HubChanged:
+		return fmt.Sprintf("%s moved from %s to %s", c.Server, c.OldHub, c.NewHub)
+	}
+	return fmt.Sprintf("%s %s", c.Server, c.Type)
+}
+
+// Event converts the change into a routing log event
+func (c LinkChange) Event(source string, at time.Time) *Event {
+	e := &Event{
+		Time:   at,
+		Source: source,
+		Text:   c.String(),
+	}
+	switch c.Type {
+	case ServerAppeared:
+		e.Type = EventLinkEstablished
+		e.Servers = []string{c.Server, c.NewHub}
+	case ServerDisappeared:
+		e.Type = EventSplit
+		e.Servers = []string{c.Server, c.OldHub}
+	case HubChanged:
+		e.Type = EventHubChanged
+		e.Servers = []string{c.Server, c.OldHub, c.NewHub}
+	}
+	return e
+}
+
+// DiffLinks compares two LINKS snapshots and returns the changes, sorted by
+// server name. Hub changes are only reported when both snapshots were taken
+// from the same server, since LINKS parents depend on where you look from.
+func DiffLinks(prev, next *LinkTree) []LinkChange {
+	var changes []LinkChange
+	sameRoot := prev.Root() == next.Root()
+
+	for server, entry := range next.entries {
+		old, ok := prev.entries[server]
+		switch {
+		case !ok:
+			changes = append(changes, LinkChange{Type: ServerAppeared, Server: server, NewHub: entry.Hub})
+		case sameRoot && old.Hub != entry.Hub:
+			changes = append(changes, LinkChange{Type: HubChanged, Server: server, OldHub: old.Hub, NewHub: entry.Hub})
+		}
+	}
+
+	for server, entry := range prev.entries {
+		if _, ok := next.entries[server]; !ok {
+			changes = append(changes, LinkChange{Type: ServerDisappeared, Server: server, OldHub: entry.Hub})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Server < changes[j].Server
+	})
+
+	return changes
+}
@@ -0,0 +1,49 @@
+package routing
+
+import "testing"
+
+func TestDiffLinks(t *testing.T) {
+	prev := NewLinkTree()
+	prev.Add("hub1.dal.net", "hub1.dal.net", 0, "Hub 1")
+	prev.Add("hub2.dal.net", "hub1.dal.net", 1, "Hub 2")
+	prev.Add("server1.dal.net", "hub1.dal.net", 1, "Server 1")
+	prev.Add("server2.dal.net", "hub2.dal.net", 2, "Server 2")
+
+	next := NewLinkTree()
+	next.Add("hub1.dal.net", "hub1.dal.net", 0, "Hub 1")
+	next.Add("hub2.dal.net", "hub1.dal.net", 1, "Hub 2")
+	next.Add("server1.dal.net", "hub2.dal.net", 2, "Server 1")
+	next.Add("server3.dal.net", "hub1.dal.net", 1, "Server 3")
+
+	changes := DiffLinks(prev, next)
+
+	expected := []LinkChange{
+		{Type: HubChanged, Server: "server1.dal.net", OldHub: "hub1.dal.net", NewHub: "hub2.dal.net"},
+		{Type: ServerDisappeared, Server: "server2.dal.net", OldHub: "hub2.dal.net"},
+		{Type: ServerAppeared, Server: "server3.dal.net", NewHub: "hub1.dal.net"},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("Change %d: expected %+v, got %+v", i, expected[i], changes[i])
+		}
+	}
+}
+
+func TestDiffLinksDifferentRoot(t *testing.T) {
+	prev := NewLinkTree()
+	prev.Add("hub1.dal.net", "hub1.dal.net", 0, "Hub 1")
+	prev.Add("server1.dal.net", "hub1.dal.net", 1, "Server 1")
+
+	// Same network seen from the other end
+	next := NewLinkTree()
+	next.Add("server1.dal.net", "server1.dal.net", 0, "Server 1")
+	next.Add("hub1.dal.net", "server1.dal.net", 1, "Hub 1")
+
+	if changes := DiffLinks(prev, next); len(changes) != 0 {
+		t.Errorf("Expected no changes across a viewpoint change, got %+v", changes)
+	}
+}
@@ -0,0 +1,121 @@
+package routing
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EventType classifies a routing notice
+type EventType string
+
+const (
+	EventLinkEstablished  EventType = "link"
+	EventSplit            EventType = "split"
+	EventLinkStats        EventType = "linkstats"
+	EventConnecting       EventType = "connecting"
+	EventConnectFailed    EventType = "connectfail"
+	EventRemoteConnect    EventType = "connect"
+	EventServerIntroduced EventType = "introduced"
+	EventSynched          EventType = "synched"
+	EventHubChanged       EventType = "moved"
+	EventOther            EventType = "other"
+)
+
+// EventTypes lists every event type, for help text and validation
+var EventTypes = []EventType{
+	EventLinkEstablished, EventSplit, EventLinkStats, EventConnecting,
+	EventConnectFailed, EventRemoteConnect, EventServerIntroduced,
+	EventSynched, EventHubChanged, EventOther,
+}
+
+// Event is a routing notice parsed into its parts
+type Event struct {
+	Time     time.Time
+	Source   string // Short name of the server that delivered the notice
+	Type     EventType
+	Reporter string   // Server the notice is "from"
+	Servers  []string // Peer servers the notice is about
+	Reason   string
+	Text     string // Notice text without the "*** Routing -- from " prefix
+}
+
+// serverName matches a server name with an optional [user@host] suffix
+const serverName = `([^\s\[\],:]+)(?:\[[^\]]*\])?`
+
+// noticePatterns are tried in order; submatches after the first are servers
+// unless the pattern is marked as having a trailing reason
+var noticePatterns = []struct {
+	re     *regexp.Regexp
+	typ    EventType
+	reason bool // Last submatch is the reason
+}{
+	{regexp.MustCompile(`(?i)^Link with ` + serverName + ` established`), EventLinkEstablished, false},
+	{regexp.MustCompile(`(?i)^Received SQUIT (\S+) from (\S+) \((.*)\)`), EventSplit, true},
+	{regexp.MustCompile(`(?i)^Server ` + serverName + ` closed the connection`), EventSplit, false},
+	{regexp.MustCompile(`(?i)^Lost server connection to ` + serverName + `:?\s*(.*)`), EventSplit, true},
+	{regexp.MustCompile(`(?i)^Write error to ` + serverName + `,\s*(.*)`), EventSplit, true},
+	{regexp.MustCompile(`(?i)^` + serverName + ` was connected for (.*)`), EventLinkStats, true},
+	{regexp.MustCompile(`(?i)^Connection to ` + serverName + ` activated`), EventConnecting, false},
+	{regexp.MustCompile(`(?i)^Connect to (?:host )?` + serverName + ` failed:?\s*(.*)`), EventConnectFailed, true},
+	{regexp.MustCompile(`(?i)^Remote CONNECT (\S+) \S+ from (\S+)`), EventRemoteConnect, false},
+	{regexp.MustCompile(`(?i)^Server (\S+) being introduced by (\S+)`), EventServerIntroduced, false},
+	{regexp.MustCompile(`(?i)^(\S+) introducing U:lined server (\S+)`), EventServerIntroduced, false},
+	{regexp.MustCompile(`(?i)^(\S+) has processed topology burst`), EventSynched, false},
+	{regexp.MustCompile(`(?i)^(\S+) has synched to network data`), EventSynched, false},
+	// Changes recorded by LINKS polling
+	{regexp.MustCompile(`^(\S+) appeared, linked to (\S+)`), EventLinkEstablished, false},
+	{regexp.MustCompile(`^(\S+) disappeared, was linked to (\S+)`), EventSplit, false},
+	{regexp.MustCompile(`^(\S+) moved from (\S+) to (\S+)`), EventHubChanged, false},
+}
+
+// reporterPrefix matches the "server: " at the start of a routing notice
+var reporterPrefix = regexp.MustCompile(`^([\w.-]+\.[\w.-]+):\s+`)
+
+// ParseNotice parses routing notice text into an event. source is the short
+// name of the server the notice arrived from. Unrecognised notices are
+// returned with type EventOther.
+func ParseNotice(source, text string, at time.Time) *Event {
+	e := &Event{
+		Time:   at,
+		Source: source,
+		Type:   EventOther,
+		Text:   text,
+	}
+
+	body := text
+	if m := reporterPrefix.FindStringSubmatch(body); m != nil {
+		e.Reporter = m[1]
+		body = body[len(m[0]):]
+	}
+
+	for _, p := range noticePatterns {
+		m := p.re.FindStringSubmatch(body)
+		if m == nil {
+			continue
+		}
+		parts := m[1:]
+		if p.reason && len(parts) > 0 {
+			e.Reason = strings.TrimSpace(parts[len(parts)-1])
+			parts = parts[:len(parts)-1]
+		}
+		e.Type = p.typ
+		e.Servers = parts
+		break
+	}
+
+	return e
+}
+
+// Involves reports whether the event concerns the given server, matching
+// the delivering, reporting and peer servers by name prefix
+func (e *Event) Involves(server string) bool {
+	server = strings.ToLower(server)
+	names := append([]string{e.Source, e.Reporter}, e.Servers...)
+	for _, name := range names {
+		if name != "" && strings.HasPrefix(strings.ToLower(name), server) {
+			return true
+		}
+	}
+	return false
+}
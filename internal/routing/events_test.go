@@ -0,0 +1,82 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNotice(t *testing.T) {
+	at := time.Date(2025, 2, 20, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		text     string
+		typ      EventType
+		reporter string
+		servers  []string
+		reason   string
+	}{
+		{
+			"hub.dal.net: Link with leaf.dal.net[unknown@255.255.255.255] established: (TS) link",
+			EventLinkEstablished, "hub.dal.net", []string{"leaf.dal.net"}, "",
+		},
+		{
+			"hub.dal.net: Received SQUIT leaf.dal.net from oper.dal.net (rerouting)",
+			EventSplit, "hub.dal.net", []string{"leaf.dal.net", "oper.dal.net"}, "rerouting",
+		},
+		{
+			"hub.dal.net: Lost server connection to leaf.dal.net: Ping timeout",
+			EventSplit, "hub.dal.net", []string{"leaf.dal.net"}, "Ping timeout",
+		},
+		{
+			"hub.dal.net: Connect to host leaf.dal.net[10.0.0.1] failed: Connection refused",
+			EventConnectFailed, "hub.dal.net", []string{"leaf.dal.net"}, "Connection refused",
+		},
+		{
+			"hub.dal.net: Server leaf.dal.net being introduced by hub2.dal.net",
+			EventServerIntroduced, "hub.dal.net", []string{"leaf.dal.net", "hub2.dal.net"}, "",
+		},
+		{
+			"hub.dal.net: leaf.dal.net[10.0.0.1] was connected for 3600 seconds.  10/20 sendK/recvK.",
+			EventLinkStats, "hub.dal.net", []string{"leaf.dal.net"}, "3600 seconds.  10/20 sendK/recvK.",
+		},
+		{
+			"hub.dal.net: something we have never seen",
+			EventOther, "hub.dal.net", nil, "",
+		},
+	}
+
+	for _, tt := range tests {
+		e := ParseNotice("hub", tt.text, at)
+		if e.Type != tt.typ {
+			t.Errorf("%q: expected type %s, got %s", tt.text, tt.typ, e.Type)
+		}
+		if e.Reporter != tt.reporter {
+			t.Errorf("%q: expected reporter %q, got %q", tt.text, tt.reporter, e.Reporter)
+		}
+		if len(e.Servers) != len(tt.servers) {
+			t.Errorf("%q: expected servers %v, got %v", tt.text, tt.servers, e.Servers)
+		} else {
+			for i := range tt.servers {
+				if e.Servers[i] != tt.servers[i] {
+					t.Errorf("%q: expected servers %v, got %v", tt.text, tt.servers, e.Servers)
+					break
+				}
+			}
+		}
+		if e.Reason != tt.reason {
+			t.Errorf("%q: expected reason %q, got %q", tt.text, tt.reason, e.Reason)
+		}
+	}
+}
+
+func TestEventInvolves(t *testing.T) {
+	at := time.Date(2025, 2, 20, 12, 0, 0, 0, time.UTC)
+	e := ParseNotice("hub", "hub.dal.net: Link with leaf.dal.net[unknown@255.255.255.255] established: (TS) link", at)
+
+	if e.Type != EventLinkEstablished || e.Source != "hub" || !e.Time.Equal(at) {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+	if !e.Involves("leaf") || !e.Involves("HUB") || e.Involves("other") {
+		t.Errorf("Involves mismatch for %+v", e)
+	}
+}
@@ -0,0 +1,221 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// TopologyDir is the directory under the data dir that topologies are
+// written to on each poll
+const TopologyDir = "topology"
+
+// Export formats
+const (
+	FormatDOT     = "dot"
+	FormatJSON    = "json"
+	FormatMermaid = "mermaid"
+)
+
+// ExportFormats lists the export formats with their file extensions
+var ExportFormats = map[string]string{
+	FormatDOT:     ".dot",
+	FormatJSON:    ".json",
+	FormatMermaid: ".mmd",
+}
+
+// Topology kinds
+const (
+	TopologyLive = "live" // From LINKS
+	TopologyMap  = "map"  // Intended, from the routing map
+)
+
+// TopologyNode is a server in an exported topology
+type TopologyNode struct {
+	Name        string `json:"name"` // Short server name
+	Role        Role   `json:"role,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// TopologyLink is a link between a server and its hub
+type TopologyLink struct {
+	Server string `json:"server"`
+	Hub    string `json:"hub"`
+	// Priority is the hub's rank in the server's assignments (1 for
+	// primary) in a map topology, 0 for a live link
+	Priority int `json:"priority,omitempty"`
+}
+
+// Topology is a network graph for export
+type Topology struct {
+	Kind  string         `json:"kind"`
+	Root  string         `json:"root,omitempty"` // Server the LINKS were taken from
+	Nodes []TopologyNode `json:"nodes"`
+	Links []TopologyLink `json:"links"`
+}
+
+// Topology returns the live topology. Roles come from rmap when given.
+func (t *LinkTree) Topology(rmap *Map) *Topology {
+	topo := &Topology{
+		Kind:  TopologyLive,
+		Root:  shortName(t.Root()),
+		Nodes: []TopologyNode{},
+		Links: []TopologyLink{},
+	}
+	for _, name := range t.order {
+		entry := t.entries[name]
+		node := TopologyNode{Name: shortName(entry.Server), Description: entry.Description}
+		if rmap != nil {
+			if mapEntry := rmap.Entry(node.Name); mapEntry != nil {
+				node.Role = mapEntry.Role
+			}
+		}
+		topo.Nodes = append(topo.Nodes, node)
+		if entry.Server != entry.Hub {
+			topo.Links = append(topo.Links, TopologyLink{Server: node.Name, Hub: shortName(entry.Hub)})
+		}
+	}
+	return topo
+}
+
+// Topology returns the intended topology: every server linked to each of
+// its assigned hubs, ranked by priority
+func (m *Map) Topology() *Topology {
+	topo := &Topology{
+		Kind:  TopologyMap,
+		Nodes: []TopologyNode{},
+		Links: []TopologyLink{},
+	}
+	seen := make(map[string]bool)
+	addNode := func(name string, role Role) {
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			topo.Nodes = append(topo.Nodes, TopologyNode{Name: name, Role: role})
+		}
+	}
+
+	for _, name := range m.ServerList {
+		var role Role
+		if entry := m.Entry(name); entry != nil {
+			role = entry.Role
+		}
+		addNode(name, role)
+	}
+	for _, name := range m.ServerList {
+		for i, hub := range m.assignments(name) {
+			addNode(hub, RoleHub)
+			topo.Links = append(topo.Links, TopologyLink{Server: name, Hub: hub, Priority: i + 1})
+		}
+	}
+	return topo
+}
+
+// ParseTopology reads a topology exported as JSON
+func ParseTopology(data []byte) (*Topology, error) {
+	var topo Topology
+	if err := json.Unmarshal(data, &topo); err != nil {
+		return nil, err
+	}
+	return &topo, nil
+}
+
+// Export renders the topology in the given format
+func (t *Topology) Export(format string) ([]byte, error) {
+	switch format {
+	case FormatDOT:
+		return []byte(t.DOT()), nil
+	case FormatJSON:
+		return json.MarshalIndent(t, "", "  ")
+	case FormatMermaid:
+		return []byte(t.Mermaid()), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// WriteFiles writes the topology in every export format to dir as
+// <kind>.dot, <kind>.json and <kind>.mmd. Each file is replaced atomically so
+// readers never see a partial write.
+func (t *Topology) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for format, ext := range ExportFormats {
+		data, err := t.Export(format)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(dir, t.Kind+ext), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DOT renders the topology as an undirected Graphviz graph. Hubs are boxes;
+// in a map topology backup links are dashed (secondary) or dotted.
+func (t *Topology) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "graph %s {\n", t.Kind)
+	for _, node := range t.Nodes {
+		var attrs []string
+		if node.Role == RoleHub {
+			attrs = append(attrs, "shape=box")
+		}
+		if node.Name == t.Root {
+			attrs = append(attrs, "style=bold")
+		}
+		if node.Description != "" {
+			attrs = append(attrs, fmt.Sprintf("tooltip=%q", node.Description))
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, "  %q [%s];\n", node.Name, strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&b, "  %q;\n", node.Name)
+		}
+	}
+	for _, link := range t.Links {
+		switch {
+		case link.Priority == 2:
+			fmt.Fprintf(&b, "  %q -- %q [style=dashed];\n", link.Server, link.Hub)
+		case link.Priority > 2:
+			fmt.Fprintf(&b, "  %q -- %q [style=dotted];\n", link.Server, link.Hub)
+		default:
+			fmt.Fprintf(&b, "  %q -- %q;\n", link.Server, link.Hub)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaidID matches characters not allowed in a Mermaid node id
+var mermaidID = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Mermaid renders the topology as a Mermaid flowchart. Backup links in a
+// map topology are dotted.
+func (t *Topology) Mermaid() string {
+	id := func(name string) string {
+		return "s_" + mermaidID.ReplaceAllString(name, "_")
+	}
+
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	for _, node := range t.Nodes {
+		if node.Role == RoleHub {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", id(node.Name), node.Name)
+		} else {
+			fmt.Fprintf(&b, "  %s(\"%s\")\n", id(node.Name), node.Name)
+		}
+	}
+	for _, link := range t.Links {
+		if link.Priority > 1 {
+			fmt.Fprintf(&b, "  %s -.- %s\n", id(link.Hub), id(link.Server))
+		} else {
+			fmt.Fprintf(&b, "  %s --- %s\n", id(link.Hub), id(link.Server))
+		}
+	}
+	return b.String()
+}
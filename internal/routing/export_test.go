@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLiveTopologyExport(t *testing.T) {
+	tree := NewLinkTree()
+	tree.Add("hub1.dal.net", "hub1.dal.net", 0, "Hub 1")
+	tree.Add("leaf1.dal.net", "hub1.dal.net", 1, "Leaf 1")
+
+	rmap := newMap()
+	rmap.Entries["hub1"] = &Server{Name: "hub1", Role: RoleHub}
+
+	topo := tree.Topology(rmap)
+	if topo.Root != "hub1" || len(topo.Nodes) != 2 || len(topo.Links) != 1 {
+		t.Fatalf("Unexpected topology: %+v", topo)
+	}
+
+	dot := topo.DOT()
+	for _, want := range []string{"graph live {", `"hub1" [shape=box, style=bold`, `"leaf1" -- "hub1";`} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+
+	mermaid := topo.Mermaid()
+	for _, want := range []string{"graph TD", `s_hub1["hub1"]`, "s_hub1 --- s_leaf1"} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, mermaid)
+		}
+	}
+
+	data, err := topo.Export(FormatJSON)
+	if err != nil {
+		t.Fatalf("JSON export failed: %v", err)
+	}
+	back, err := ParseTopology(data)
+	if err != nil {
+		t.Fatalf("ParseTopology failed: %v", err)
+	}
+	if back.Kind != TopologyLive || len(back.Links) != 1 || back.Links[0].Hub != "hub1" {
+		t.Errorf("JSON round trip lost data: %+v", back)
+	}
+
+	if _, err := topo.Export("svg"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+
+	dir := filepath.Join(t.TempDir(), TopologyDir)
+	if err := topo.WriteFiles(dir); err != nil {
+		t.Fatalf("WriteFiles failed: %v", err)
+	}
+	for _, name := range []string{"live.dot", "live.json", "live.mmd"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+		}
+	}
+}
+
+func TestMapTopology(t *testing.T) {
+	rmap := newMap()
+	for _, s := range []*Server{
+		{Name: "hub1", Role: RoleHub},
+		{Name: "leaf1", Role: RoleClient, Hubs: []string{"hub1", "hub2"}},
+	} {
+		rmap.Entries[s.Name] = s
+		rmap.ServerList = append(rmap.ServerList, s.Name)
+	}
+
+	topo := rmap.Topology()
+	if len(topo.Nodes) != 3 {
+		t.Errorf("Expected hub2 to be added as a node, got %+v", topo.Nodes)
+	}
+
+	dot := topo.DOT()
+	if !strings.Contains(dot, `"leaf1" -- "hub2" [style=dashed];`) {
+		t.Errorf("Secondary link should be dashed:\n%s", dot)
+	}
+	if !strings.Contains(topo.Mermaid(), "s_hub2 -.- s_leaf1") {
+		t.Errorf("Secondary link should be dotted in Mermaid")
+	}
+}
@@ -0,0 +1,143 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FixAction is an oper command in a reroute plan
+type FixAction string
+
+const (
+	FixSquit   FixAction = "SQUIT"
+	FixConnect FixAction = "CONNECT"
+)
+
+// FixStep is one command of a reroute plan
+type FixStep struct {
+	Action FixAction
+	Server string // Full name of the server to squit or connect
+	Hub    string // Full name of the hub to connect it to (CONNECT only)
+	Reason string
+}
+
+// Command formats the step as an oper would type it. port is the server
+// port for CONNECT, or 0 to leave a placeholder.
+func (s FixStep) Command(port int) string {
+	if s.Action == FixSquit {
+		return fmt.Sprintf("SQUIT %s :%s", s.Server, s.Reason)
+	}
+	portText := "<port>"
+	if port > 0 {
+		portText = fmt.Sprintf("%d", port)
+	}
+	return fmt.Sprintf("CONNECT %s %s %s", s.Server, portText, s.Hub)
+}
+
+// FixPlan is an ordered list of commands that would bring misrouted and
+// missing servers back to their best available hub
+type FixPlan struct {
+	Steps   []FixStep
+	Skipped []string // Servers that can't be fixed, with the reason
+}
+
+// PlanFixes works out how to reroute every misrouted server and reconnect
+// every missing one to the highest-priority assigned hub that is linked.
+// Hubs are fixed before leaves so leaves land on a correctly routed hub.
+// A misrouted server can only be moved to a hub that its SQUIT would not
+// split off with it, and never if it is the server the LINKS came from.
+func PlanFixes(tree *LinkTree, rmap *Map) *FixPlan {
+	plan := &FixPlan{}
+	domain := serverDomain(tree.Root())
+
+	type fix struct {
+		server string // Short name
+		full   string // Full name, as linked or guessed
+		hub    string // Full name of the target hub
+		squit  bool
+		reason string
+	}
+	var fixes []fix
+
+	// bestHub returns the full name of the first assigned hub that is linked
+	// and not in split
+	bestHub := func(hubs []string, split map[string]bool) string {
+		for _, hub := range hubs {
+			if name := tree.Find(hub); name != "" && !split[name] {
+				return name
+			}
+		}
+		return ""
+	}
+
+	for _, e := range CheckCompliance(tree, rmap).Filter(Misrouted) {
+		full := tree.Find(e.Server)
+		if strings.EqualFold(full, tree.Root()) {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s is misrouted but is the server I am connected to, reroute it from another server", e.Server))
+			continue
+		}
+		hub := bestHub(e.Expected, tree.behind(full))
+		if hub == "" {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s is misrouted but none of its hubs (%s) are linked outside the servers behind it", e.Server, strings.Join(e.Expected, " ")))
+			continue
+		}
+		reason := fmt.Sprintf("Rerouting from %s to %s", e.Hub, shortName(hub))
+		fixes = append(fixes, fix{server: e.Server, full: full, hub: hub, squit: true, reason: reason})
+	}
+
+	_, _, missing := CompareToMap(tree, rmap)
+	for _, server := range missing {
+		hubs := rmap.assignments(server)
+		hub := bestHub(hubs, nil)
+		if hub == "" {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s is missing but none of its hubs (%s) are linked", server, strings.Join(hubs, " ")))
+			continue
+		}
+		fixes = append(fixes, fix{server: server, full: server + domain, hub: hub, reason: "Missing"})
+	}
+
+	isHub := func(server string) bool {
+		entry := rmap.Entry(server)
+		return entry != nil && entry.Role == RoleHub
+	}
+	sort.SliceStable(fixes, func(i, j int) bool {
+		hi, hj := isHub(fixes[i].server), isHub(fixes[j].server)
+		if hi != hj {
+			return hi
+		}
+		return fixes[i].server < fixes[j].server
+	})
+
+	for _, f := range fixes {
+		if f.squit {
+			plan.Steps = append(plan.Steps, FixStep{Action: FixSquit, Server: f.full, Reason: f.reason})
+		}
+		plan.Steps = append(plan.Steps, FixStep{Action: FixConnect, Server: f.full, Hub: f.hub, Reason: f.reason})
+	}
+	return plan
+}
+
+// behind returns server and every server linked through it, as seen from
+// the root: the servers a SQUIT of server would split off
+func (t *LinkTree) behind(server string) map[string]bool {
+	split := make(map[string]bool)
+	for name := range t.entries {
+		for _, up := range t.ancestors(name) {
+			if up == server {
+				split[name] = true
+				break
+			}
+		}
+	}
+	return split
+}
+
+// serverDomain returns the domain part of a server name, with its leading
+// dot, or "" if there is none
+func serverDomain(server string) string {
+	if idx := strings.Index(server, "."); idx > 0 {
+		return server[idx:]
+	}
+	return ""
+}
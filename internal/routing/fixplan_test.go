@@ -0,0 +1,103 @@
+package routing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanFixes(t *testing.T) {
+	tree := NewLinkTree()
+	tree.Add("hub1.dal.net", "hub1.dal.net", 0, "Hub 1")
+	tree.Add("hub2.dal.net", "hub1.dal.net", 1, "Hub 2")
+	tree.Add("leaf1.dal.net", "hub1.dal.net", 1, "Leaf 1")
+	tree.Add("leaf2.dal.net", "hub1.dal.net", 1, "Leaf 2")
+
+	rmap := newMap()
+	for _, s := range []*Server{
+		{Name: "hub1", Role: RoleHub, Hubs: []string{"hub2"}},
+		{Name: "hub2", Role: RoleHub, Hubs: []string{"hub1"}},
+		{Name: "leaf1", Role: RoleClient, Hubs: []string{"hub1", "hub2"}},
+		{Name: "leaf2", Role: RoleClient, Hubs: []string{"hub2", "hub3"}},
+		{Name: "leaf3", Role: RoleClient, Hubs: []string{"hub3", "hub2"}},
+		{Name: "leaf4", Role: RoleClient, Hubs: []string{"hub3"}},
+	} {
+		rmap.Entries[s.Name] = s
+		rmap.ServerList = append(rmap.ServerList, s.Name)
+	}
+
+	plan := PlanFixes(tree, rmap)
+
+	want := []string{
+		"SQUIT leaf2.dal.net :Rerouting from hub1 to hub2",
+		"CONNECT leaf2.dal.net 7325 hub2.dal.net",
+		"CONNECT leaf3.dal.net 7325 hub2.dal.net",
+	}
+	if len(plan.Steps) != len(want) {
+		t.Fatalf("Expected %d steps, got %+v", len(want), plan.Steps)
+	}
+	for i, step := range plan.Steps {
+		if got := step.Command(7325); got != want[i] {
+			t.Errorf("Step %d: expected %q, got %q", i, want[i], got)
+		}
+	}
+
+	if len(plan.Skipped) != 1 {
+		t.Errorf("Expected leaf4 to be skipped, got %v", plan.Skipped)
+	}
+
+	if got := plan.Steps[1].Command(0); got != "CONNECT leaf2.dal.net <port> hub2.dal.net" {
+		t.Errorf("Expected a port placeholder, got %q", got)
+	}
+}
+
+func TestPlanFixesSplit(t *testing.T) {
+	// Seen from leaf5, which is itself misrouted; hub2 is misrouted with
+	// hub3 and hub4 behind it
+	tree := NewLinkTree()
+	tree.Add("leaf5.dal.net", "leaf5.dal.net", 0, "Leaf 5")
+	tree.Add("hub1.dal.net", "leaf5.dal.net", 1, "Hub 1")
+	tree.Add("hub5.dal.net", "hub1.dal.net", 2, "Hub 5")
+	tree.Add("hub2.dal.net", "hub1.dal.net", 2, "Hub 2")
+	tree.Add("hub3.dal.net", "hub2.dal.net", 3, "Hub 3")
+	tree.Add("hub4.dal.net", "hub3.dal.net", 4, "Hub 4")
+
+	rmap := newMap()
+	for _, s := range []*Server{
+		{Name: "hub1", Role: RoleHub, Hubs: []string{"hub5"}},
+		{Name: "hub2", Role: RoleHub, Hubs: []string{"hub4", "hub5"}},
+		{Name: "hub3", Role: RoleHub, Hubs: []string{"hub2"}},
+		{Name: "hub4", Role: RoleHub, Hubs: []string{"hub3"}},
+		{Name: "hub5", Role: RoleHub, Hubs: []string{"hub1"}},
+		{Name: "leaf5", Role: RoleClient, Hubs: []string{"hub5"}},
+	} {
+		rmap.Entries[s.Name] = s
+		rmap.ServerList = append(rmap.ServerList, s.Name)
+	}
+
+	plan := PlanFixes(tree, rmap)
+
+	// hub4 would go with hub2, so hub2 moves to hub5; leaf5 can't be
+	// squit from itself
+	want := []string{
+		"SQUIT hub2.dal.net :Rerouting from hub1 to hub5",
+		"CONNECT hub2.dal.net 7325 hub5.dal.net",
+	}
+	if len(plan.Steps) != len(want) {
+		t.Fatalf("Expected %d steps, got %+v", len(want), plan.Steps)
+	}
+	for i, step := range plan.Steps {
+		if got := step.Command(7325); got != want[i] {
+			t.Errorf("Step %d: expected %q, got %q", i, want[i], got)
+		}
+	}
+	if len(plan.Skipped) != 1 || !strings.Contains(plan.Skipped[0], "leaf5") {
+		t.Errorf("Expected leaf5 to be skipped, got %v", plan.Skipped)
+	}
+
+	// With no hub left outside the split, hub2 is skipped too
+	rmap.Entries["hub2"].Hubs = []string{"hub4"}
+	plan = PlanFixes(tree, rmap)
+	if len(plan.Steps) != 0 || len(plan.Skipped) != 2 {
+		t.Errorf("Expected hub2 and leaf5 to be skipped, got %+v", plan)
+	}
+}
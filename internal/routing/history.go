@@ -0,0 +1,155 @@
+package routing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryDir is the directory under the data dir that map versions are
+// kept in
+const HistoryDir = "maps"
+
+// MapVersion describes a saved copy of the routing map
+type MapVersion struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Loader  string    `json:"loader"` // Who loaded it, or "startup"
+	Hash    string    `json:"hash"`   // SHA-256 of the map text
+}
+
+// String formats the version as "v3 (2006-01-02 15:04 by loader)"
+func (v MapVersion) String() string {
+	return fmt.Sprintf("v%d (%s by %s)", v.Version, v.Time.UTC().Format("2006-01-02 15:04"), v.Loader)
+}
+
+// MapHistory keeps every distinct routing map that was loaded, as
+// maps/rmap.<version>.txt with an index in maps/versions.json
+type MapHistory struct {
+	dir string
+
+	mu       sync.Mutex
+	versions []MapVersion // Oldest first
+}
+
+// Hash returns the SHA-256 of the map text, hex encoded
+func (m *Map) Hash() string {
+	sum := sha256.Sum256([]byte(m.text()))
+	return hex.EncodeToString(sum[:])
+}
+
+// text returns the map as it was read from the file
+func (m *Map) text() string {
+	if len(m.Raw) == 0 {
+		return ""
+	}
+	return strings.Join(m.Raw, "\n") + "\n"
+}
+
+// OpenMapHistory reads the version index in dataDir, creating the history
+// directory if needed
+func OpenMapHistory(dataDir string) (*MapHistory, error) {
+	h := &MapHistory{dir: filepath.Join(dataDir, HistoryDir)}
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", h.dir, err)
+	}
+
+	data, err := os.ReadFile(h.indexPath())
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &h.versions); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", h.indexPath(), err)
+	}
+	return h, nil
+}
+
+func (h *MapHistory) indexPath() string {
+	return filepath.Join(h.dir, "versions.json")
+}
+
+func (h *MapHistory) versionPath(version int) string {
+	return filepath.Join(h.dir, fmt.Sprintf("rmap.%d.txt", version))
+}
+
+// Record saves m as a new version unless it is the same as the latest one.
+// Returns the latest version and whether it was just added.
+func (h *MapHistory) Record(m *Map, loader string, now time.Time) (MapVersion, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hash := m.Hash()
+	if n := len(h.versions); n > 0 && h.versions[n-1].Hash == hash {
+		return h.versions[n-1], false, nil
+	}
+
+	v := MapVersion{Version: len(h.versions) + 1, Time: now.UTC(), Loader: loader, Hash: hash}
+	if n := len(h.versions); n > 0 {
+		v.Version = h.versions[n-1].Version + 1
+	}
+	if err := writeFileAtomic(h.versionPath(v.Version), []byte(m.text())); err != nil {
+		return MapVersion{}, false, err
+	}
+
+	data, err := json.MarshalIndent(append(h.versions, v), "", "  ")
+	if err != nil {
+		return MapVersion{}, false, err
+	}
+	if err := writeFileAtomic(h.indexPath(), data); err != nil {
+		return MapVersion{}, false, err
+	}
+	h.versions = append(h.versions, v)
+	return v, true, nil
+}
+
+// Versions returns every saved version, oldest first
+func (h *MapHistory) Versions() []MapVersion {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]MapVersion(nil), h.versions...)
+}
+
+// Version returns a saved version by number
+func (h *MapHistory) Version(version int) (MapVersion, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, v := range h.versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return MapVersion{}, false
+}
+
+// Load parses a saved version of the map
+func (h *MapHistory) Load(version int) (*Map, error) {
+	if _, ok := h.Version(version); !ok {
+		return nil, fmt.Errorf("no map version %d", version)
+	}
+	path := h.versionPath(version)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("map version %d is gone: %w", version, err)
+	}
+	return LoadMapFile(path)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
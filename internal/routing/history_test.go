@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMapHistory(t *testing.T) {
+	dir := t.TempDir()
+	rmapPath := filepath.Join(dir, "rmap.txt")
+	load := func(content string) *Map {
+		if err := os.WriteFile(rmapPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := LoadMap(dir)
+		if err != nil {
+			t.Fatalf("LoadMap failed: %v", err)
+		}
+		return m
+	}
+
+	h, err := OpenMapHistory(dir)
+	if err != nil {
+		t.Fatalf("OpenMapHistory failed: %v", err)
+	}
+
+	now := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	first := load("hub1: hub2\nleaf1: hub1 hub2\nleaf2: hub1\n")
+	if v, added, err := h.Record(first, "startup", now); err != nil || !added || v.Version != 1 {
+		t.Fatalf("Expected version 1 to be added, got %+v %v %v", v, added, err)
+	}
+	if _, added, _ := h.Record(first, "alice", now); added {
+		t.Errorf("An unchanged map should not be recorded again")
+	}
+
+	second := load("hub1: hub2\nleaf1: hub2 hub1\nleaf3: hub1\n")
+	v, added, err := h.Record(second, "bob", now.Add(time.Hour))
+	if err != nil || !added || v.Version != 2 || v.String() != "v2 (2026-01-02 04:04 by bob)" {
+		t.Fatalf("Unexpected second version %s (added %v, err %v)", v, added, err)
+	}
+
+	// The index survives reopening
+	h, err = OpenMapHistory(dir)
+	if err != nil {
+		t.Fatalf("OpenMapHistory failed: %v", err)
+	}
+	if len(h.Versions()) != 2 {
+		t.Fatalf("Expected 2 versions, got %+v", h.Versions())
+	}
+
+	old, err := h.Load(1)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	diff := DiffMaps(old, second)
+	if len(diff.Added) != 1 || diff.Added[0] != "leaf3" {
+		t.Errorf("Expected leaf3 to be added, got %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != "leaf2" {
+		t.Errorf("Expected leaf2 to be removed, got %v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Server != "leaf1" || !diff.Changed[0].PrimaryChanged() {
+		t.Errorf("Expected leaf1's primary hub to change, got %+v", diff.Changed)
+	}
+	if !diff.Involves("LEAF1") || diff.Involves("hub1") {
+		t.Errorf("Involves gave the wrong answer")
+	}
+	if diff.Empty() || !DiffMaps(second, second).Empty() {
+		t.Errorf("Empty gave the wrong answer")
+	}
+
+	if _, err := h.Load(7); err == nil {
+		t.Errorf("Expected an error for a missing version")
+	}
+}
@@ -8,10 +8,10 @@ import (
 
 // LinkEntry represents a single server link from LINKS response
 type LinkEntry struct {
-	Server      string // Server name
-	Hub         string // Connected to (upstream)
-	Hops        int    // Hop count
-	Description string // Server description
+	Server      string `json:"server"`      // Server name
+	Hub         string `json:"hub"`         // Connected to (upstream)
+	Hops        int    `json:"hops"`        // Hop count
+	Description string `json:"description"` // Server description
 }
 
 // LinkTree holds the collected LINKS data
@@ -44,15 +44,35 @@ func (t *LinkTree) GetLinkedServers() []string {
 	var servers []string
 	for server := range t.entries {
 		// Extract short name (before first dot)
-		short := server
-		if idx := strings.Index(server, "."); idx > 0 {
-			short = server[:idx]
-		}
-		servers = append(servers, short)
+		servers = append(servers, shortName(server))
 	}
 	return servers
 }
 
+// Root returns the server the LINKS were collected from, or "" if unknown
+func (t *LinkTree) Root() string {
+	for server, entry := range t.entries {
+		if entry.Hops == 0 {
+			return server
+		}
+	}
+	return ""
+}
+
+// Entries returns the link entries in the order LINKS listed them
+func (t *LinkTree) Entries() []LinkEntry {
+	entries := make([]LinkEntry, 0, len(t.order))
+	for _, name := range t.order {
+		entries = append(entries, *t.entries[name])
+	}
+	return entries
+}
+
+// Len returns the number of servers in the tree
+func (t *LinkTree) Len() int {
+	return len(t.entries)
+}
+
 // Build constructs the sorted tree and returns formatted lines
 func (t *LinkTree) Build() []string {
 	if len(t.entries) == 0 {
@@ -60,13 +80,7 @@ func (t *LinkTree) Build() []string {
 	}
 
 	// Find the root (hops == 0)
-	var root string
-	for server, entry := range t.entries {
-		if entry.Hops == 0 {
-			root = server
-			break
-		}
-	}
+	root := t.Root()
 
 	if root == "" {
 		return []string{"Error: no root server found"}
@@ -148,6 +162,8 @@ func (t *LinkTree) hasMoreAtLevel(level int, remaining []string) bool {
 
 // CompareToMap compares linked servers against the routing map
 // Returns (total in map, linked count, missing servers)
+// Servers listed under LOA are not expected to be linked and are never
+// reported as missing.
 func CompareToMap(tree *LinkTree, rmap *Map) (int, int, []string) {
 	linked := tree.GetLinkedServers()
 	linkedSet := make(map[string]bool)
@@ -157,10 +173,10 @@ func CompareToMap(tree *LinkTree, rmap *Map) (int, int, []string) {
 
 	var missing []string
 	for _, server := range rmap.ServerList {
-		short := server
-		if idx := strings.Index(server, "."); idx > 0 {
-			short = server[:idx]
+		if entry := rmap.Entry(server); entry != nil && entry.LOA {
+			continue
 		}
+		short := shortName(server)
 		if !linkedSet[strings.ToLower(short)] {
 			missing = append(missing, short)
 		}
@@ -168,3 +184,11 @@ func CompareToMap(tree *LinkTree, rmap *Map) (int, int, []string) {
 
 	return len(rmap.ServerList), len(linked), missing
 }
+
+// shortName strips the domain from a server name
+func shortName(server string) string {
+	if idx := strings.Index(server, "."); idx > 0 {
+		return server[:idx]
+	}
+	return server
+}
@@ -82,3 +82,25 @@ func TestCompareToMap(t *testing.T) {
 		t.Errorf("Expected 2 missing, got %d: %v", len(missing), missing)
 	}
 }
+
+func TestCompareToMapSkipsLOA(t *testing.T) {
+	tree := NewLinkTree()
+	tree.Add("hub.dal.net", "hub.dal.net", 0, "Hub")
+
+	rmap := &Map{
+		ServerList: []string{"hub", "server1"},
+		Servers: map[string][]string{
+			"hub":     {},
+			"server1": {"hub"},
+		},
+		Entries: map[string]*Server{
+			"hub":     {Name: "hub"},
+			"server1": {Name: "server1", Hubs: []string{"hub"}, LOA: true},
+		},
+	}
+
+	_, _, missing := CompareToMap(tree, rmap)
+	if len(missing) != 0 {
+		t.Errorf("LOA server should not be missing: %v", missing)
+	}
+}
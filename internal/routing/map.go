@@ -2,14 +2,78 @@ package routing
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-// skipPatterns matches lines to ignore when parsing the routing map
-var skipPatterns = regexp.MustCompile(`(?i)^Tier|^Hub:|^Client:|^Special:|^LOA|===|DALnet Routing|^Temporary|^---|^\s*$`)
+// skipPatterns matches lines that are not server entries in the routing map,
+// including headers such as "Secondary hubs: ..." and any line mentioning the
+// Routing team
+var skipPatterns = regexp.MustCompile(`(?i)^Tier|^Hub:|^Client:|^Special:|^LOA|===|DALnet Routing|(?-i:Routing)|^Secondary\s|^Temporary|^---|^\s*$`)
+
+// Section header patterns
+var (
+	tierHeader      = regexp.MustCompile(`(?i)^Tier\s*(\d+)`)
+	hubHeader       = regexp.MustCompile(`(?i)^Hub:`)
+	clientHeader    = regexp.MustCompile(`(?i)^Client:`)
+	specialHeader   = regexp.MustCompile(`(?i)^Special:`)
+	loaHeader       = regexp.MustCompile(`(?i)^LOA`)
+	temporaryHeader = regexp.MustCompile(`(?i)^Temporary`)
+	separatorLine   = regexp.MustCompile(`===|^---`)
+)
+
+// Role describes the part a server plays in the network
+type Role string
+
+const (
+	RoleHub      Role = "hub"
+	RoleClient   Role = "client"
+	RoleServices Role = "services"
+	RoleSpecial  Role = "special"
+)
+
+// Server is a single server entry parsed from the routing map
+type Server struct {
+	Name      string   `json:"name"`
+	Hubs      []string `json:"hubs"`               // Uplinks in priority order (primary first)
+	Comments  []string `json:"comments,omitempty"` // Parenthesised and "=" annotations
+	Tier      int      `json:"tier,omitempty"`     // Tier from the enclosing header, 0 if none
+	Role      Role     `json:"role"`
+	LOA       bool     `json:"loa,omitempty"`       // Listed in an LOA section
+	Temporary bool     `json:"temporary,omitempty"` // Listed in a temporary assignments section
+	Line      int      `json:"line"`                // Line number in rmap.txt (1-based)
+}
+
+// String formats the entry the way it appears in the map, with its flags
+func (s *Server) String() string {
+	var b strings.Builder
+	b.WriteString(s.Name)
+	b.WriteString(":")
+	if len(s.Hubs) > 0 {
+		b.WriteString(" ")
+		b.WriteString(strings.Join(s.Hubs, " "))
+	}
+	for _, c := range s.Comments {
+		fmt.Fprintf(&b, " (%s)", c)
+	}
+	if s.LOA {
+		b.WriteString(" [LOA]")
+	}
+	if s.Temporary {
+		b.WriteString(" [temporary]")
+	}
+	return b.String()
+}
+
+// Section is a block of the routing map introduced by a header line
+type Section struct {
+	Title   string
+	Servers []*Server
+}
 
 // Map represents the routing configuration
 type Map struct {
@@ -19,65 +83,240 @@ type Map struct {
 	Servers map[string][]string
 	// ServerList is an ordered list of server names
 	ServerList []string
+	// Entries maps server name to its parsed entry
+	Entries map[string]*Server
+	// Sections holds the map's header blocks in file order
+	Sections []*Section
+
+	// problems holds lines the parser could not make sense of
+	problems []Problem
+}
+
+// newMap returns an empty map ready for parsing
+func newMap() *Map {
+	return &Map{
+		Raw:        []string{},
+		Servers:    make(map[string][]string),
+		ServerList: []string{},
+		Entries:    make(map[string]*Server),
+	}
 }
 
 // LoadMap reads and parses the routing map file
 func LoadMap(dataDir string) (*Map, error) {
-	path := filepath.Join(dataDir, "rmap.txt")
+	return LoadMapFile(filepath.Join(dataDir, "rmap.txt"))
+}
+
+// LoadMapFile reads and parses a routing map from path. A missing file
+// gives an empty map.
+func LoadMapFile(path string) (*Map, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return &Map{
-				Raw:        []string{},
-				Servers:    make(map[string][]string),
-				ServerList: []string{},
-			}, nil
+			return newMap(), nil
 		}
 		return nil, err
 	}
 	defer file.Close()
 
-	m := &Map{
-		Raw:     []string{},
-		Servers: make(map[string][]string),
-	}
+	m := newMap()
+	p := &mapParser{m: m}
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
 		// Clean carriage returns
-		line = strings.ReplaceAll(line, "\r", "")
+		line := strings.ReplaceAll(scanner.Text(), "\r", "")
 		m.Raw = append(m.Raw, line)
+		p.parseLine(line, len(m.Raw))
+	}
+	p.finish()
+
+	return m, scanner.Err()
+}
+
+// mapParser tracks the header state while walking the rmap lines
+type mapParser struct {
+	m         *Map
+	section   *Section
+	tier      int
+	role      Role
+	loa       bool
+	temporary bool
+}
+
+func (p *mapParser) parseLine(line string, lineNo int) {
+	trimmed := strings.TrimSpace(line)
+
+	if skipPatterns.MatchString(line) {
+		p.parseHeader(trimmed)
+		return
+	}
+
+	// Parse server: hub1 hub2 hub3 format
+	if !strings.Contains(line, ":") {
+		p.problem(lineNo, SeverityWarning, "", fmt.Sprintf("ignored line %q: not a server entry or section header", trimmed))
+		return
+	}
+	parts := strings.SplitN(line, ":", 2)
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		p.problem(lineNo, SeverityError, "", "entry has no server name before the colon")
+		return
+	}
+	if strings.ContainsAny(name, " \t") {
+		p.problem(lineNo, SeverityError, "", fmt.Sprintf("%q is not a valid server name", name))
+		return
+	}
+	if strings.Count(parts[1], "(") != strings.Count(parts[1], ")") {
+		p.problem(lineNo, SeverityError, name, "unbalanced parentheses in the assignment")
+	}
 
-		// Skip non-server lines
-		if skipPatterns.MatchString(line) {
+	hubs, comments := parseAssignment(parts[1])
+
+	tier, role := p.tier, p.role
+	if strings.HasPrefix(strings.ToLower(name), "services") {
+		role = RoleServices
+	}
+	// A temporary assignment changes a server's hubs, not what it is
+	if prev, exists := p.m.Entries[name]; exists && p.temporary {
+		tier, role = prev.Tier, prev.Role
+	}
+
+	entry := &Server{
+		Name:      name,
+		Hubs:      hubs,
+		Comments:  comments,
+		Tier:      tier,
+		Role:      role,
+		LOA:       p.loa,
+		Temporary: p.temporary,
+		Line:      lineNo,
+	}
+
+	// A later entry (e.g. a temporary assignment) overrides an earlier one
+	if prev, exists := p.m.Entries[name]; !exists {
+		p.m.ServerList = append(p.m.ServerList, name)
+	} else if !p.temporary {
+		p.problem(lineNo, SeverityError, name, fmt.Sprintf("duplicate entry, already listed on line %d", prev.Line))
+	}
+	p.m.Entries[name] = entry
+	p.m.Servers[name] = hubs
+
+	if p.section == nil {
+		p.section = &Section{}
+		p.m.Sections = append(p.m.Sections, p.section)
+	}
+	p.section.Servers = append(p.section.Servers, entry)
+}
+
+// problem records a parse problem
+func (p *mapParser) problem(lineNo int, severity Severity, server, message string) {
+	p.m.problems = append(p.m.problems, Problem{Line: lineNo, Severity: severity, Server: server, Message: message})
+}
+
+// parseHeader updates the parser state from a non-server line
+func (p *mapParser) parseHeader(trimmed string) {
+	switch {
+	case trimmed == "":
+		return
+	case separatorLine.MatchString(trimmed):
+		// A separator closes any LOA or temporary block
+		p.loa = false
+		p.temporary = false
+		return
+	case tierHeader.MatchString(trimmed):
+		p.tier, _ = strconv.Atoi(tierHeader.FindStringSubmatch(trimmed)[1])
+		p.role = ""
+		if strings.Contains(strings.ToLower(trimmed), "hub") {
+			p.role = RoleHub
+		}
+		p.loa = false
+		p.temporary = false
+	case hubHeader.MatchString(trimmed):
+		p.role = RoleHub
+	case clientHeader.MatchString(trimmed):
+		p.role = RoleClient
+	case specialHeader.MatchString(trimmed):
+		p.role = RoleSpecial
+	case loaHeader.MatchString(trimmed):
+		p.loa = true
+	case temporaryHeader.MatchString(trimmed):
+		p.temporary = true
+	default:
+		// Map title and other decoration
+		return
+	}
+
+	p.section = &Section{Title: trimmed}
+	p.m.Sections = append(p.m.Sections, p.section)
+}
+
+// finish fills in roles that were not set by a section header
+func (p *mapParser) finish() {
+	referenced := make(map[string]bool)
+	for _, hubs := range p.m.Servers {
+		for _, h := range hubs {
+			referenced[strings.ToLower(h)] = true
+		}
+	}
+	for _, entry := range p.m.Entries {
+		if entry.Role != "" {
 			continue
 		}
+		if referenced[strings.ToLower(entry.Name)] {
+			entry.Role = RoleHub
+		} else {
+			entry.Role = RoleClient
+		}
+	}
+}
 
-		// Parse server: hub1 hub2 hub3 format
-		if strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			server := strings.TrimSpace(parts[0])
-			if server == "" {
-				continue
-			}
+// parseAssignment splits the text after "server:" into hub names and
+// annotations. Parenthesised text and anything after "=" are annotations.
+func parseAssignment(text string) ([]string, []string) {
+	var hubs, comments []string
+	var plain strings.Builder
 
-			var hubs []string
-			if len(parts) > 1 {
-				hubPart := strings.TrimSpace(parts[1])
-				for _, h := range strings.Fields(hubPart) {
-					// Skip non-hub entries (comments, etc)
-					if !strings.HasPrefix(h, "(") && !strings.HasPrefix(h, "=") {
-						hubs = append(hubs, h)
-					}
-				}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			end := strings.IndexByte(text[i:], ')')
+			if end < 0 {
+				end = len(text) - i
+			}
+			if c := strings.TrimSpace(text[i+1 : i+end]); c != "" {
+				comments = append(comments, c)
 			}
-			m.Servers[server] = hubs
-			m.ServerList = append(m.ServerList, server)
+			i += end
+		case '=':
+			if c := strings.TrimSpace(strings.Trim(text[i:], "= ")); c != "" {
+				comments = append(comments, c)
+			}
+			i = len(text)
+		default:
+			plain.WriteByte(text[i])
 		}
 	}
 
-	return m, scanner.Err()
+	hubs = strings.Fields(plain.String())
+	return hubs, comments
+}
+
+// Entry returns the parsed entry for a server, or nil if unknown
+func (m *Map) Entry(server string) *Server {
+	if m.Entries == nil {
+		return nil
+	}
+	if entry, ok := m.Entries[server]; ok {
+		return entry
+	}
+	server = strings.ToLower(server)
+	for name, entry := range m.Entries {
+		if strings.ToLower(name) == server {
+			return entry
+		}
+	}
+	return nil
 }
 
 // GetUplinks returns the hub assignments for a server
@@ -107,3 +107,89 @@ func TestGetUplinks(t *testing.T) {
 		t.Errorf("Expected nil for nonexistent server, got %v", hubs)
 	}
 }
+
+func TestLoadMapStructured(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rnexus-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	rmapContent := `DALnet Routing Team Map
+===========================
+Tier 1 Hubs
+hub1: hub2
+hub2: hub1
+
+Tier 2
+Client:
+server1: hub1 hub2 (new box) = moving soon
+services: hub1
+special1: hub2
+
+===========================
+LOA
+server2: hub2
+
+===========================
+Temporary assignments
+server1: hub2
+hub2: hub1 (while hub1 is rebuilt)
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "rmap.txt"), []byte(rmapContent), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := LoadMap(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadMap failed: %v", err)
+	}
+
+	// server1 appears twice but should only be listed once
+	if len(m.ServerList) != 6 {
+		t.Errorf("Expected 6 servers, got %d: %v", len(m.ServerList), m.ServerList)
+	}
+
+	// A server named special... is not a Special: header
+	if special := m.Entry("special1"); special == nil || special.Role != RoleClient || len(special.Hubs) != 1 {
+		t.Errorf("Unexpected special1 entry: %+v", special)
+	}
+
+	hub := m.Entry("hub1")
+	if hub == nil || hub.Tier != 1 || hub.Role != RoleHub {
+		t.Errorf("Unexpected hub1 entry: %+v", hub)
+	}
+
+	svc := m.Entry("services")
+	if svc == nil || svc.Role != RoleServices || svc.Tier != 2 {
+		t.Errorf("Unexpected services entry: %+v", svc)
+	}
+
+	loa := m.Entry("server2")
+	if loa == nil || !loa.LOA {
+		t.Errorf("server2 should be on LOA: %+v", loa)
+	}
+
+	// The temporary assignment overrides the permanent one
+	tmp := m.Entry("server1")
+	if tmp == nil || !tmp.Temporary || len(tmp.Hubs) != 1 || tmp.Hubs[0] != "hub2" {
+		t.Errorf("Unexpected server1 entry: %+v", tmp)
+	}
+
+	// and keeps the tier and role from it, not from the headers above
+	if tmpHub := m.Entry("hub2"); tmpHub == nil || !tmpHub.Temporary || tmpHub.Tier != 1 || tmpHub.Role != RoleHub {
+		t.Errorf("Unexpected hub2 entry: %+v", tmpHub)
+	}
+}
+
+func TestParseAssignment(t *testing.T) {
+	hubs, comments := parseAssignment(" hub1 hub2 (until next week) hub3 = see ticket")
+
+	if len(hubs) != 3 || hubs[0] != "hub1" || hubs[1] != "hub2" || hubs[2] != "hub3" {
+		t.Errorf("Unexpected hubs: %v", hubs)
+	}
+	if len(comments) != 2 || comments[0] != "until next week" || comments[1] != "see ticket" {
+		t.Errorf("Unexpected comments: %q", comments)
+	}
+}
@@ -0,0 +1,108 @@
+package routing
+
+import (
+	"sort"
+	"strings"
+)
+
+// HubChange is a server whose hub assignments differ between two maps
+type HubChange struct {
+	Server string
+	Old    []string
+	New    []string
+}
+
+// PrimaryChanged reports whether the server's primary hub changed
+func (c HubChange) PrimaryChanged() bool {
+	var oldPrimary, newPrimary string
+	if len(c.Old) > 0 {
+		oldPrimary = strings.ToLower(c.Old[0])
+	}
+	if len(c.New) > 0 {
+		newPrimary = strings.ToLower(c.New[0])
+	}
+	return oldPrimary != newPrimary
+}
+
+// MapDiff lists the differences between two routing maps. Names are sorted.
+type MapDiff struct {
+	Added   []string
+	Removed []string
+	Changed []HubChange
+}
+
+// Empty reports whether the maps assign the same hubs to the same servers
+func (d *MapDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Involves reports whether server was added, removed or reassigned
+func (d *MapDiff) Involves(server string) bool {
+	for _, name := range d.Added {
+		if strings.EqualFold(name, server) {
+			return true
+		}
+	}
+	for _, name := range d.Removed {
+		if strings.EqualFold(name, server) {
+			return true
+		}
+	}
+	for _, c := range d.Changed {
+		if strings.EqualFold(c.Server, server) {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffMaps compares the effective hub assignments (temporary ones included)
+// of two maps. Server names are compared case-insensitively.
+func DiffMaps(old, new *Map) *MapDiff {
+	diff := &MapDiff{}
+
+	oldNames := make(map[string]string)
+	for _, name := range old.ServerList {
+		oldNames[strings.ToLower(name)] = name
+	}
+	newNames := make(map[string]string)
+	for _, name := range new.ServerList {
+		newNames[strings.ToLower(name)] = name
+	}
+
+	for key, name := range newNames {
+		oldName, ok := oldNames[key]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		oldHubs, newHubs := old.Servers[oldName], new.Servers[name]
+		if !sameHubs(oldHubs, newHubs) {
+			diff.Changed = append(diff.Changed, HubChange{Server: name, Old: oldHubs, New: newHubs})
+		}
+	}
+	for key, name := range oldNames {
+		if _, ok := newNames[key]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].Server < diff.Changed[j].Server
+	})
+	return diff
+}
+
+func sameHubs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
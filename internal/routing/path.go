@@ -0,0 +1,106 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Find returns the tree's name for a server given its full or short name,
+// or "" if it is not linked
+func (t *LinkTree) Find(name string) string {
+	if _, ok := t.entries[name]; ok {
+		return name
+	}
+	for server := range t.entries {
+		if strings.EqualFold(server, name) || strings.EqualFold(shortName(server), name) {
+			return server
+		}
+	}
+	return ""
+}
+
+// ancestors returns server followed by each hub above it up to the root
+func (t *LinkTree) ancestors(server string) []string {
+	chain := []string{server}
+	seen := map[string]bool{server: true}
+	for {
+		entry := t.entries[server]
+		if entry == nil || entry.Hub == entry.Server || seen[entry.Hub] {
+			return chain
+		}
+		server = entry.Hub
+		seen[server] = true
+		chain = append(chain, server)
+	}
+}
+
+// Path returns the servers traffic passes through from a to b, both ends
+// included, following each server's LinkEntry.Hub
+func (t *LinkTree) Path(a, b string) ([]string, error) {
+	from, to := t.Find(a), t.Find(b)
+	if from == "" {
+		return nil, fmt.Errorf("%s is not linked", a)
+	}
+	if to == "" {
+		return nil, fmt.Errorf("%s is not linked", b)
+	}
+	return joinChains(t.ancestors(from), t.ancestors(to)), nil
+}
+
+// PrimaryPath returns the path from a to b if every server were linked to
+// its primary hub. Hubs with no assignment of their own are taken to link
+// to each other directly.
+func (m *Map) PrimaryPath(a, b string) ([]string, error) {
+	from, to := m.Entry(shortName(a)), m.Entry(shortName(b))
+	if from == nil {
+		return nil, fmt.Errorf("%s is not in the routing map", a)
+	}
+	if to == nil {
+		return nil, fmt.Errorf("%s is not in the routing map", b)
+	}
+	return joinChains(m.primaryChain(from.Name), m.primaryChain(to.Name)), nil
+}
+
+// primaryChain returns server followed by its primary hub, that hub's
+// primary hub and so on
+func (m *Map) primaryChain(server string) []string {
+	chain := []string{server}
+	seen := map[string]bool{strings.ToLower(server): true}
+	for {
+		entry := m.Entry(server)
+		if entry == nil || len(entry.Hubs) == 0 || seen[strings.ToLower(entry.Hubs[0])] {
+			return chain
+		}
+		server = entry.Hubs[0]
+		if hub := m.Entry(server); hub != nil {
+			server = hub.Name
+		}
+		seen[strings.ToLower(server)] = true
+		chain = append(chain, server)
+	}
+}
+
+// joinChains joins two chains running up towards a root at the first server
+// they share. Chains with nothing in common are joined end to end.
+func joinChains(up, down []string) []string {
+	index := make(map[string]int)
+	for i, server := range up {
+		index[strings.ToLower(server)] = i
+	}
+
+	for j, server := range down {
+		if i, ok := index[strings.ToLower(server)]; ok {
+			path := append([]string{}, up[:i+1]...)
+			for k := j - 1; k >= 0; k-- {
+				path = append(path, down[k])
+			}
+			return path
+		}
+	}
+
+	path := append([]string{}, up...)
+	for k := len(down) - 1; k >= 0; k-- {
+		path = append(path, down[k])
+	}
+	return path
+}
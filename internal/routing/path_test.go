@@ -0,0 +1,63 @@
+package routing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinkTreePath(t *testing.T) {
+	tree := NewLinkTree()
+	tree.Add("hub1.dal.net", "hub1.dal.net", 0, "Hub 1")
+	tree.Add("hub2.dal.net", "hub1.dal.net", 1, "Hub 2")
+	tree.Add("leaf1.dal.net", "hub1.dal.net", 1, "Leaf 1")
+	tree.Add("leaf2.dal.net", "hub2.dal.net", 2, "Leaf 2")
+
+	path, err := tree.Path("leaf1", "LEAF2.dal.net")
+	if err != nil {
+		t.Fatalf("Path failed: %v", err)
+	}
+	want := "leaf1.dal.net hub1.dal.net hub2.dal.net leaf2.dal.net"
+	if got := strings.Join(path, " "); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	path, _ = tree.Path("leaf2", "hub2")
+	if got := strings.Join(path, " "); got != "leaf2.dal.net hub2.dal.net" {
+		t.Errorf("Unexpected path to own hub: %s", got)
+	}
+
+	if _, err := tree.Path("leaf1", "nowhere"); err == nil {
+		t.Errorf("Expected an error for an unlinked server")
+	}
+}
+
+func TestPrimaryPath(t *testing.T) {
+	rmap := newMap()
+	for _, s := range []*Server{
+		{Name: "hub1"},
+		{Name: "hub2"},
+		{Name: "leaf1", Hubs: []string{"hub1", "hub2"}},
+		{Name: "leaf2", Hubs: []string{"hub2", "hub1"}},
+		{Name: "leaf3", Hubs: []string{"hub2"}},
+	} {
+		rmap.Entries[s.Name] = s
+		rmap.ServerList = append(rmap.ServerList, s.Name)
+	}
+
+	path, err := rmap.PrimaryPath("leaf1.dal.net", "leaf2")
+	if err != nil {
+		t.Fatalf("PrimaryPath failed: %v", err)
+	}
+	if got := strings.Join(path, " "); got != "leaf1 hub1 hub2 leaf2" {
+		t.Errorf("Unexpected primary path: %s", got)
+	}
+
+	path, _ = rmap.PrimaryPath("leaf2", "leaf3")
+	if got := strings.Join(path, " "); got != "leaf2 hub2 leaf3" {
+		t.Errorf("Unexpected primary path: %s", got)
+	}
+
+	if _, err := rmap.PrimaryPath("leaf1", "stray"); err == nil {
+		t.Errorf("Expected an error for a server missing from the map")
+	}
+}
@@ -0,0 +1,163 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity is how serious a routing map problem is
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning" // Suspicious, but the map is usable
+	SeverityError   Severity = "error"   // The map does not say what was meant
+)
+
+// Problem is something wrong with the routing map
+type Problem struct {
+	Line     int // Line number in rmap.txt, 0 if not tied to one line
+	Severity Severity
+	Server   string // Server the problem is about, if any
+	Message  string
+}
+
+// String formats the problem as "line N: severity: [server] message"
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", p.Line)
+	}
+	fmt.Fprintf(&b, "%s: ", p.Severity)
+	if p.Server != "" {
+		fmt.Fprintf(&b, "%s: ", p.Server)
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// HasErrors reports whether any of the problems is an error
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the map for syntax problems, duplicate entries, servers
+// that uplink to themselves or have no hubs, hubs that are unknown or not
+// listed as hubs, and cycles in the primary hubs of hubs. Problems are
+// sorted by line.
+func (m *Map) Validate() []Problem {
+	problems := append([]Problem(nil), m.problems...)
+	add := func(entry *Server, severity Severity, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			Line:     entry.Line,
+			Severity: severity,
+			Server:   entry.Name,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Check every entry, including ones a temporary assignment overrides
+	for _, entry := range m.allEntries() {
+		if len(entry.Hubs) == 0 {
+			add(entry, SeverityWarning, "no primary hub assigned")
+		}
+		for _, hub := range entry.Hubs {
+			hubEntry := m.Entry(hub)
+			switch {
+			case strings.EqualFold(hub, entry.Name):
+				add(entry, SeverityError, "lists itself as an uplink")
+			case hubEntry == nil:
+				add(entry, SeverityWarning, "hub %s is not in the map", hub)
+			case hubEntry.Role != RoleHub:
+				add(entry, SeverityWarning, "hub %s is listed as %s, not as a hub", hub, hubEntry.Role)
+			}
+		}
+	}
+
+	problems = append(problems, m.primaryCycles()...)
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
+
+// primaryCycles reports hubs whose chain of primary hubs loops back on
+// itself instead of reaching the top of the network. Tier 1 hubs usually
+// back each other up, so a loop made only of tier 1 hubs is not reported.
+func (m *Map) primaryCycles() []Problem {
+	var problems []Problem
+	reported := make(map[string]bool)
+
+	for _, name := range m.ServerList {
+		entry := m.Entry(name)
+		if entry == nil || entry.Role != RoleHub {
+			continue
+		}
+
+		// Follow primary hubs until the chain ends or repeats
+		var chain []*Server
+		index := make(map[string]int)
+		for current := entry; current != nil && len(current.Hubs) > 0; current = m.Entry(current.Hubs[0]) {
+			key := strings.ToLower(current.Name)
+			if start, seen := index[key]; seen {
+				problems = append(problems, cycleProblem(chain[start:], reported)...)
+				break
+			}
+			index[key] = len(chain)
+			chain = append(chain, current)
+		}
+	}
+	return problems
+}
+
+// cycleProblem reports a loop of primary hubs once, against its first
+// entry in the map
+func cycleProblem(loop []*Server, reported map[string]bool) []Problem {
+	first := loop[0]
+	topTier := true
+	for _, s := range loop {
+		if reported[strings.ToLower(s.Name)] {
+			return nil
+		}
+		if s.Tier != 1 {
+			topTier = false
+		}
+		if s.Line < first.Line {
+			first = s
+		}
+	}
+	for _, s := range loop {
+		reported[strings.ToLower(s.Name)] = true
+	}
+	// A hub that is its own uplink is reported as such
+	if topTier || len(loop) == 1 {
+		return nil
+	}
+
+	names := make([]string, 0, len(loop)+1)
+	for _, s := range loop {
+		names = append(names, s.Name)
+	}
+	names = append(names, loop[0].Name)
+	return []Problem{{
+		Line:     first.Line,
+		Severity: SeverityWarning,
+		Server:   first.Name,
+		Message:  "primary hubs form a cycle: " + strings.Join(names, " -> "),
+	}}
+}
+
+// allEntries returns every entry in file order
+func (m *Map) allEntries() []*Server {
+	var entries []*Server
+	for _, section := range m.Sections {
+		entries = append(entries, section.Servers...)
+	}
+	return entries
+}
@@ -0,0 +1,118 @@
+package routing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateMap(t *testing.T) {
+	rmapContent := `DALnet Routing Team Map
+===========================
+Tier 1 Hubs
+hub1: hub2
+hub2: hub1
+
+Client:
+server1: hub1 hub2 (new box
+server2: hub1 hub9
+server1: hub2
+oops, forgot a colon
+bad name: hub1
+
+===========================
+Temporary assignments
+server2: hub2
+`
+	path := filepath.Join(t.TempDir(), "rmap.txt")
+	if err := os.WriteFile(path, []byte(rmapContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := LoadMapFile(path)
+	if err != nil {
+		t.Fatalf("LoadMapFile failed: %v", err)
+	}
+
+	var got []string
+	for _, p := range m.Validate() {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"line 8: error: server1: unbalanced parentheses in the assignment",
+		"line 9: warning: server2: hub hub9 is not in the map",
+		"line 10: error: server1: duplicate entry, already listed on line 8",
+		`line 11: warning: ignored line "oops, forgot a colon": not a server entry or section header`,
+		`line 12: error: "bad name" is not a valid server name`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected problems:\n%s", strings.Join(got, "\n"))
+	}
+	if !HasErrors(m.Validate()) {
+		t.Errorf("Expected errors")
+	}
+}
+
+func TestValidateMapAssignments(t *testing.T) {
+	rmap := newMap()
+	for i, s := range []*Server{
+		{Name: "hub1", Role: RoleHub, Tier: 1, Hubs: []string{"hub2"}},
+		{Name: "hub2", Role: RoleHub, Tier: 1, Hubs: []string{"hub1"}},
+		{Name: "hub3", Role: RoleHub, Tier: 2, Hubs: []string{"hub4", "hub1"}},
+		{Name: "hub4", Role: RoleHub, Tier: 2, Hubs: []string{"hub3"}},
+		{Name: "leaf1", Role: RoleClient, Hubs: []string{"leaf1", "leaf2"}},
+		{Name: "leaf2", Role: RoleClient},
+	} {
+		s.Line = i + 1
+		rmap.Entries[s.Name] = s
+		rmap.ServerList = append(rmap.ServerList, s.Name)
+	}
+	rmap.Sections = []*Section{{}}
+	for _, name := range rmap.ServerList {
+		rmap.Sections[0].Servers = append(rmap.Sections[0].Servers, rmap.Entries[name])
+	}
+
+	var got []string
+	for _, p := range rmap.Validate() {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"line 3: warning: hub3: primary hubs form a cycle: hub3 -> hub4 -> hub3",
+		"line 5: error: leaf1: lists itself as an uplink",
+		"line 5: warning: leaf1: hub leaf2 is listed as client, not as a hub",
+		"line 6: warning: leaf2: no primary hub assigned",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected problems:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestValidateMapHeaders(t *testing.T) {
+	rmapContent := `DALnet Routing Team Map
+Maintained by the Routing Team: routing@dal.net
+===========================
+Tier 1 Hubs
+hub1: hub2
+hub2: hub1
+
+Secondary hubs: see below
+Client:
+server1: hub1 hub2
+`
+	path := filepath.Join(t.TempDir(), "rmap.txt")
+	if err := os.WriteFile(path, []byte(rmapContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := LoadMapFile(path)
+	if err != nil {
+		t.Fatalf("LoadMapFile failed: %v", err)
+	}
+	if problems := m.Validate(); len(problems) != 0 {
+		t.Errorf("Expected header lines to be skipped, got %v", problems)
+	}
+	if len(m.ServerList) != 3 {
+		t.Errorf("Expected 3 servers, got %v", m.ServerList)
+	}
+}
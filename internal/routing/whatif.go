@@ -0,0 +1,124 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Relink is where a server split off by a hub failure should link next
+type Relink struct {
+	Server    string    // Short server name
+	Hub       string    // Hub to relink to, "" if no assignment is left
+	Placement Placement // Rank of Hub in the server's assignments
+	Expected  []string  // Hub assignments from the map
+}
+
+// Impact is the result of taking a hub out of the network
+type Impact struct {
+	Hub     string   // Short name of the removed hub
+	Split   []string // Short names of the servers that would be cut off
+	Relinks []Relink // Where each split server with an assignment should go
+}
+
+// SimulateHubFailure removes hub from the live tree and reports which
+// servers would be split from the rest of the network and where each should
+// relink according to its map assignments.
+//
+// The surviving network is the largest group of servers still connected
+// once the hub is gone, so the answer mostly doesn't depend on which server
+// the LINKS were taken from.
+func SimulateHubFailure(tree *LinkTree, rmap *Map, hub string) (*Impact, error) {
+	name := tree.Find(hub)
+	if name == "" {
+		return nil, fmt.Errorf("%s is not linked", hub)
+	}
+	removed := strings.ToLower(shortName(name))
+	neighbours := tree.adjacency()
+
+	// Group the remaining servers into connected components
+	component := make(map[string]int)
+	var sizes []int
+	for server := range neighbours {
+		if server == removed {
+			continue
+		}
+		if _, done := component[server]; done {
+			continue
+		}
+		id := len(sizes)
+		size := 0
+		queue := []string{server}
+		component[server] = id
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			size++
+			for _, next := range neighbours[current] {
+				if next == removed {
+					continue
+				}
+				if _, done := component[next]; !done {
+					component[next] = id
+					queue = append(queue, next)
+				}
+			}
+		}
+		sizes = append(sizes, size)
+	}
+
+	// Ties go to the side we are connected to
+	rootID := -1
+	if id, ok := component[strings.ToLower(shortName(tree.Root()))]; ok {
+		rootID = id
+	}
+	network := -1
+	for id, size := range sizes {
+		if network < 0 || size > sizes[network] || (size == sizes[network] && id == rootID) {
+			network = id
+		}
+	}
+
+	impact := &Impact{Hub: shortName(name)}
+	for _, entry := range tree.entries {
+		server := shortName(entry.Server)
+		key := strings.ToLower(server)
+		if key == removed {
+			continue
+		}
+		if id, ok := component[key]; ok && id == network {
+			continue
+		}
+		// A lone server with no links left has no component
+		impact.Split = append(impact.Split, server)
+	}
+	sort.Strings(impact.Split)
+
+	for _, server := range impact.Split {
+		hubs := rmap.assignments(server)
+		if len(hubs) == 0 {
+			continue
+		}
+
+		relink := Relink{Server: server, Expected: hubs}
+		for i, candidate := range hubs {
+			key := strings.ToLower(shortName(candidate))
+			if key == removed {
+				continue
+			}
+			if id, ok := component[key]; !ok || id != network {
+				continue
+			}
+			relink.Hub = candidate
+			if i < len(placementRanks) {
+				relink.Placement = placementRanks[i]
+			} else {
+				relink.Placement = OnTertiary
+			}
+			break
+		}
+		impact.Relinks = append(impact.Relinks, relink)
+	}
+
+	return impact, nil
+}
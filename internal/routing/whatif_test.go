@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSimulateHubFailure(t *testing.T) {
+	// Viewed from leaf1, which sits on hub1
+	tree := NewLinkTree()
+	tree.Add("leaf1.dal.net", "leaf1.dal.net", 0, "Leaf 1")
+	tree.Add("hub1.dal.net", "leaf1.dal.net", 1, "Hub 1")
+	tree.Add("hub2.dal.net", "hub1.dal.net", 2, "Hub 2")
+	tree.Add("hub3.dal.net", "hub2.dal.net", 3, "Hub 3")
+	tree.Add("leaf2.dal.net", "hub2.dal.net", 3, "Leaf 2")
+	tree.Add("leaf3.dal.net", "hub2.dal.net", 3, "Leaf 3")
+	tree.Add("leaf4.dal.net", "hub3.dal.net", 4, "Leaf 4")
+
+	rmap := &Map{
+		Servers: map[string][]string{
+			"leaf1": {"hub1", "hub3"},
+			"leaf2": {"hub2", "hub1", "hub3"},
+			"leaf3": {"hub2"},
+		},
+	}
+
+	impact, err := SimulateHubFailure(tree, rmap, "hub2")
+	if err != nil {
+		t.Fatalf("SimulateHubFailure failed: %v", err)
+	}
+
+	// leaf1+hub1 and hub3+leaf4 are the same size, so the side we are
+	// connected to is taken as the network
+	if got := strings.Join(impact.Split, " "); got != "hub3 leaf2 leaf3 leaf4" {
+		t.Errorf("Unexpected split servers: %s", got)
+	}
+
+	relinks := make(map[string]Relink)
+	for _, r := range impact.Relinks {
+		relinks[r.Server] = r
+	}
+	if r := relinks["leaf3"]; r.Hub != "" {
+		t.Errorf("leaf3 has nowhere to go, got %+v", r)
+	}
+	if r := relinks["leaf2"]; r.Hub != "hub1" || r.Placement != OnSecondary {
+		t.Errorf("leaf2 should move to hub1 as secondary, got %+v", r)
+	}
+
+	if _, err := SimulateHubFailure(tree, rmap, "nowhere"); err == nil {
+		t.Errorf("Expected an error for an unlinked hub")
+	}
+}
+
+func TestSimulateHubFailureLeafOnly(t *testing.T) {
+	tree := NewLinkTree()
+	tree.Add("hub1.dal.net", "hub1.dal.net", 0, "Hub 1")
+	tree.Add("hub2.dal.net", "hub1.dal.net", 1, "Hub 2")
+	tree.Add("hub3.dal.net", "hub1.dal.net", 1, "Hub 3")
+	tree.Add("leaf1.dal.net", "hub2.dal.net", 2, "Leaf 1")
+
+	rmap := &Map{Servers: map[string][]string{"leaf1": {"hub2", "hub3"}}}
+
+	impact, err := SimulateHubFailure(tree, rmap, "hub2.dal.net")
+	if err != nil {
+		t.Fatalf("SimulateHubFailure failed: %v", err)
+	}
+	if len(impact.Split) != 1 || impact.Split[0] != "leaf1" {
+		t.Fatalf("Expected leaf1 to be split, got %v", impact.Split)
+	}
+	if r := impact.Relinks[0]; r.Hub != "hub3" || r.Placement != OnSecondary {
+		t.Errorf("Expected leaf1 to relink to hub3 as secondary, got %+v", r)
+	}
+}
@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names in the bolt database
+var (
+	bucketLogs         = []byte("logs")
+	bucketLogsByServer = []byte("logs_by_server")
+	bucketStats        = []byte("stats")
+	bucketStatsByUser  = []byte("stats_by_user")
+	bucketMeta         = []byte("meta")
+
+	keyMOTD = []byte("motd")
+)
+
+// BoltStore keeps logs, stats and the MOTD in an embedded bbolt database
+// (rnexus.db in the data directory). Records are appended, never trimmed.
+//
+// Log and stat records are keyed by time so range queries are a cursor
+// walk. The *_by_* buckets index the same keys by server and by nick.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens (or creates) the database in dataDir
+func OpenBolt(dataDir string) (*BoltStore, error) {
+	path := filepath.Join(dataDir, "rnexus.db")
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketLogs, bucketLogsByServer, bucketStats, bucketStatsByUser, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise %s: %w", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// AppendLog adds a log record
+func (s *BoltStore) AppendLog(r LogRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return appendRecord(tx, bucketLogs, bucketLogsByServer, r.Time, r.Server, r)
+	})
+}
+
+// Logs returns matching log records, newest first
+func (s *BoltStore) Logs(q Query) ([]LogRecord, error) {
+	var result []LogRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return scanRecords(tx, bucketLogs, bucketLogsByServer, q.Server, q, func(v []byte) (bool, error) {
+			var r LogRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return false, err
+			}
+			if !q.matchLog(r) {
+				return true, nil
+			}
+			result = append(result, r)
+			return q.Limit == 0 || len(result) < q.Limit, nil
+		})
+	})
+	return result, err
+}
+
+// AppendStat adds a stat record
+func (s *BoltStore) AppendStat(r StatRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return appendRecord(tx, bucketStats, bucketStatsByUser, r.Time, statNick(r.User), r)
+	})
+}
+
+// Stats returns matching stat records, newest first
+func (s *BoltStore) Stats(q Query) ([]StatRecord, error) {
+	var result []StatRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return scanRecords(tx, bucketStats, bucketStatsByUser, q.User, q, func(v []byte) (bool, error) {
+			var r StatRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return false, err
+			}
+			if !q.matchStat(r) {
+				return true, nil
+			}
+			result = append(result, r)
+			return q.Limit == 0 || len(result) < q.Limit, nil
+		})
+	})
+	return result, err
+}
+
+// MOTD returns the current message of the day
+func (s *BoltStore) MOTD() (*MOTD, error) {
+	motd := &MOTD{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketMeta).Get(keyMOTD)
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, motd)
+	})
+	return motd, err
+}
+
+// SetMOTD replaces the message of the day
+func (s *BoltStore) SetMOTD(m *MOTD) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keyMOTD, data)
+	})
+}
+
+// Close closes the database
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// appendRecord stores v under a new time key in bucket and indexes that key
+// under name in index
+func appendRecord(tx *bolt.Tx, bucket, index []byte, t time.Time, name string, v interface{}) error {
+	b := tx.Bucket(bucket)
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := timeKey(t, seq)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(key, data); err != nil {
+		return err
+	}
+
+	return tx.Bucket(index).Put(indexKey(name, key), key)
+}
+
+// scanRecords walks records newest first within the query's time range,
+// calling fn with each value until it returns false. When name is set the
+// walk goes through the index instead of the whole bucket.
+func scanRecords(tx *bolt.Tx, bucket, index []byte, name string, q Query, fn func(v []byte) (bool, error)) error {
+	b := tx.Bucket(bucket)
+	if name == "" {
+		return scanBack(b.Cursor(), nil, q, func(k, v []byte) (bool, error) {
+			return fn(v)
+		})
+	}
+
+	prefix := indexKey(name, nil)
+	return scanBack(tx.Bucket(index).Cursor(), prefix, q, func(k, v []byte) (bool, error) {
+		data := b.Get(v)
+		if data == nil {
+			return true, nil
+		}
+		return fn(data)
+	})
+}
+
+// scanBack walks keys with the given prefix from q.Until back to q.Since
+func scanBack(c *bolt.Cursor, prefix []byte, q Query, fn func(k, v []byte) (bool, error)) error {
+	var upper []byte
+	if q.Until.IsZero() {
+		upper = append(append([]byte{}, prefix...), bytes.Repeat([]byte{0xff}, 16)...)
+	} else {
+		upper = append(append([]byte{}, prefix...), timeKey(q.Until, 0)...)
+	}
+	lower := append(append([]byte{}, prefix...), timeKey(q.Since, 0)...)
+
+	k, v := c.Seek(upper)
+	if k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+		if bytes.Compare(k, lower) < 0 {
+			break
+		}
+		more, err := fn(k, v)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// timeKey builds a sortable key from a timestamp and a sequence number.
+// Times before the Unix epoch (including the zero time) sort first.
+func timeKey(t time.Time, seq uint64) []byte {
+	var nanos uint64
+	if t.After(time.Unix(0, 0)) {
+		nanos = uint64(t.UnixNano())
+	}
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], nanos)
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// indexKey prefixes key with a lowercased name and a separator
+func indexKey(name string, key []byte) []byte {
+	return append([]byte(strings.ToLower(name)+"\x00"), key...)
+}
+
+// statNick returns the nick part of a stat record's user
+func statNick(user string) string {
+	if idx := strings.Index(user, "!"); idx >= 0 {
+		return user[:idx]
+	}
+	return user
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBoltLogs(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rnexus-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenBolt(tmpDir)
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+	defer store.Close()
+
+	base := time.Date(2025, 2, 20, 12, 0, 0, 0, time.UTC)
+	records := []LogRecord{
+		{Time: base, Server: "hub1", Text: "first"},
+		{Time: base.Add(time.Minute), Server: "hub2", Text: "second"},
+		{Time: base.Add(2 * time.Minute), Server: "hub1", Text: "third"},
+		{Time: base.Add(3 * time.Minute), Server: "Hub1", Text: "fourth"},
+	}
+	for _, r := range records {
+		if err := store.AppendLog(r); err != nil {
+			t.Fatalf("AppendLog failed: %v", err)
+		}
+	}
+
+	// Everything, newest first
+	all, err := store.Logs(Query{})
+	if err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	if len(all) != 4 || all[0].Text != "fourth" || all[3].Text != "first" {
+		t.Errorf("Unexpected logs: %+v", all)
+	}
+
+	// By server through the index
+	hub1, _ := store.Logs(Query{Server: "hub1"})
+	if len(hub1) != 3 || hub1[0].Text != "fourth" || hub1[2].Text != "first" {
+		t.Errorf("Unexpected hub1 logs: %+v", hub1)
+	}
+
+	// Time range and limit
+	ranged, _ := store.Logs(Query{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)})
+	if len(ranged) != 2 || ranged[0].Text != "third" || ranged[1].Text != "second" {
+		t.Errorf("Unexpected ranged logs: %+v", ranged)
+	}
+	limited, _ := store.Logs(Query{Server: "hub1", Limit: 1})
+	if len(limited) != 1 || limited[0].Text != "fourth" {
+		t.Errorf("Unexpected limited logs: %+v", limited)
+	}
+}
+
+func TestBoltStatsAndMOTD(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rnexus-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenBolt(tmpDir)
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+
+	base := time.Date(2025, 2, 20, 12, 0, 0, 0, time.UTC)
+	store.AppendStat(StatRecord{Time: base, User: "alice!a@host", Command: "!links"})
+	store.AppendStat(StatRecord{Time: base.Add(time.Second), User: "bob!b@host", Command: "!map"})
+
+	stats, _ := store.Stats(Query{User: "ALICE"})
+	if len(stats) != 1 || stats[0].Command != "!links" {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if err := store.SetMOTD(&MOTD{Setter: "alice", Message: "hello"}); err != nil {
+		t.Fatalf("SetMOTD failed: %v", err)
+	}
+	store.Close()
+
+	// Data survives reopening
+	store, err = OpenBolt(tmpDir)
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+	defer store.Close()
+
+	motd, _ := store.MOTD()
+	if motd.Setter != "alice" || motd.Message != "hello" {
+		t.Errorf("Unexpected MOTD: %+v", motd)
+	}
+	stats, _ = store.Stats(Query{})
+	if len(stats) != 2 || stats[0].Command != "!map" {
+		t.Errorf("Unexpected stats after reopen: %+v", stats)
+	}
+}
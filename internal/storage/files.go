@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 const maxEntries = 500
@@ -13,8 +14,43 @@ const maxEntries = 500
 // LoadLogs reads routing logs from file
 // Returns logs in reverse chronological order (newest first)
 func LoadLogs(dataDir string) ([]string, error) {
-	path := filepath.Join(dataDir, "logs.txt")
-	lines, err := readLines(path)
+	return loadLogFile(dataDir, "")
+}
+
+// SaveLogs writes routing logs to file
+// Expects logs in reverse chronological order (newest first)
+func SaveLogs(dataDir string, logs []string) error {
+	return saveLogFile(dataDir, "", logs)
+}
+
+// logFile returns the file a log category is kept in: logs.txt for routing
+// notices, logs-<category>.txt for the rest
+func logFile(dataDir, category string) string {
+	if category == "" {
+		return filepath.Join(dataDir, "logs.txt")
+	}
+	return filepath.Join(dataDir, "logs-"+category+".txt")
+}
+
+// logCategories returns the log categories with a file in dataDir, always
+// including "" for logs.txt
+func logCategories(dataDir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dataDir, "logs-*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	categories := []string{""}
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "logs-"), ".txt")
+		if ValidCategory(name) {
+			categories = append(categories, name)
+		}
+	}
+	return categories, nil
+}
+
+func loadLogFile(dataDir, category string) ([]string, error) {
+	lines, err := readLines(logFile(dataDir, category))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return []string{}, nil
@@ -25,12 +61,9 @@ func LoadLogs(dataDir string) ([]string, error) {
 	return reverse(lines), nil
 }
 
-// SaveLogs writes routing logs to file
-// Expects logs in reverse chronological order (newest first)
-func SaveLogs(dataDir string, logs []string) error {
-	path := filepath.Join(dataDir, "logs.txt")
+func saveLogFile(dataDir, category string, logs []string) error {
 	// Reverse back to oldest-first for file storage
-	return writeLines(path, reverse(logs))
+	return writeLines(logFile(dataDir, category), reverse(logs))
 }
 
 // LoadStats reads command stats from file
@@ -105,6 +138,140 @@ func AddStat(stats []string, entry string) []string {
 	return stats
 }
 
+// FileStore keeps logs, stats and the MOTD in text files in the data
+// directory. Each change rewrites the whole file and history is capped at
+// 500 entries.
+type FileStore struct {
+	dataDir string
+
+	mu    sync.Mutex
+	logs  map[string][]string // Category -> lines, newest first
+	stats []string            // Oldest first
+	motd  *MOTD
+}
+
+// OpenFiles loads the text files in dataDir into a FileStore
+func OpenFiles(dataDir string) (*FileStore, error) {
+	logs, err := LoadLogs(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load logs: %w", err)
+	}
+	stats, err := LoadStats(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stats: %w", err)
+	}
+	motd, err := LoadMOTD(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MOTD: %w", err)
+	}
+	return &FileStore{dataDir: dataDir, logs: map[string][]string{"": logs}, stats: stats, motd: motd}, nil
+}
+
+// categoryLogs returns the lines of a log category, reading its file the
+// first time. Must be called with s.mu held.
+func (s *FileStore) categoryLogs(category string) ([]string, error) {
+	if logs, ok := s.logs[category]; ok {
+		return logs, nil
+	}
+	if !ValidCategory(category) {
+		return nil, fmt.Errorf("invalid log category %q", category)
+	}
+	logs, err := loadLogFile(s.dataDir, category)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s logs: %w", category, err)
+	}
+	s.logs[category] = logs
+	return logs, nil
+}
+
+// AppendLog adds a log entry and rewrites its log file
+func (s *FileStore) AppendLog(r LogRecord) error {
+	category := strings.ToLower(r.Category)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logs, err := s.categoryLogs(category)
+	if err != nil {
+		return err
+	}
+	s.logs[category] = AddLog(logs, r.String())
+	return saveLogFile(s.dataDir, category, s.logs[category])
+}
+
+// Logs returns matching log entries, newest first
+func (s *FileStore) Logs(q Query) ([]LogRecord, error) {
+	category := strings.ToLower(q.Category)
+
+	s.mu.Lock()
+	logs, err := s.categoryLogs(category)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []LogRecord
+	for _, line := range logs {
+		r := parseLogLine(line)
+		r.Category = category
+		if !q.matchLog(r) {
+			continue
+		}
+		result = append(result, r)
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// AppendStat adds a stat entry and rewrites stats.txt
+func (s *FileStore) AppendStat(r StatRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = AddStat(s.stats, r.String())
+	return SaveStats(s.dataDir, s.stats)
+}
+
+// Stats returns matching stat entries, newest first
+func (s *FileStore) Stats(q Query) ([]StatRecord, error) {
+	s.mu.Lock()
+	stats := s.stats
+	s.mu.Unlock()
+
+	var result []StatRecord
+	for i := len(stats) - 1; i >= 0; i-- {
+		r := parseStatLine(stats[i])
+		if !q.matchStat(r) {
+			continue
+		}
+		result = append(result, r)
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// MOTD returns the current message of the day
+func (s *FileStore) MOTD() (*MOTD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.motd, nil
+}
+
+// SetMOTD replaces the message of the day and rewrites motd.txt
+func (s *FileStore) SetMOTD(m *MOTD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.motd = m
+	return SaveMOTD(s.dataDir, m)
+}
+
+// Close is a no-op, every change is already on disk
+func (s *FileStore) Close() error {
+	return nil
+}
+
 func readLines(path string) ([]string, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Timestamp layouts used by the text files
+const (
+	logTimeFormat  = "Mon Jan 02, 2006 15:04:05 GMT"
+	statTimeFormat = "Mon Jan 02, 2006 at 15:04:05 GMT"
+)
+
+// Backend names accepted by Open
+const (
+	BackendFiles = "files"
+	BackendBolt  = "bolt"
+)
+
+// LogRecord is a single routing log entry
+type LogRecord struct {
+	Time   time.Time `json:"time"`
+	Server string    `json:"server"` // Server the notice came from
+	Text   string    `json:"text"`
+	// Category is the notice filter that captured it, "" for routing notices
+	Category string `json:"category,omitempty"`
+}
+
+// String formats the record as a logs.txt line
+func (r LogRecord) String() string {
+	return fmt.Sprintf("[%s] [%s]: %s", r.Time.UTC().Format(logTimeFormat), r.Server, r.Text)
+}
+
+// StatRecord is a single command audit entry
+type StatRecord struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"` // Hostmask or account that issued the command
+	Command string    `json:"command"`
+}
+
+// String formats the record as a stats.txt line
+func (r StatRecord) String() string {
+	return fmt.Sprintf("%s: %s -> %s", r.Time.UTC().Format(statTimeFormat), r.User, r.Command)
+}
+
+// Query selects log or stat records. Zero values match everything, except
+// Category, which picks the log to read.
+type Query struct {
+	Since    time.Time // Inclusive
+	Until    time.Time // Exclusive
+	Server   string    // Log records from this server (case-insensitive)
+	User     string    // Stat records from this nick (case-insensitive)
+	Text     string    // Records containing this text (case-insensitive)
+	Limit    int       // Maximum records returned, 0 for no limit
+	Category string    // Log records of this category, "" for routing notices
+	// LogFilter, if set, is a further test on log records, applied during
+	// the scan so Limit still bounds the result
+	LogFilter func(LogRecord) bool
+}
+
+// matchTime reports whether t falls within the query's time range
+func (q Query) matchTime(t time.Time) bool {
+	if !q.Since.IsZero() && t.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !t.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+func (q Query) matchLog(r LogRecord) bool {
+	if !strings.EqualFold(r.Category, q.Category) {
+		return false
+	}
+	if !q.matchTime(r.Time) {
+		return false
+	}
+	if q.Server != "" && !strings.EqualFold(r.Server, q.Server) {
+		return false
+	}
+	// Match against the whole line, as searches always have
+	if q.Text != "" && !strings.Contains(strings.ToLower(r.String()), strings.ToLower(q.Text)) {
+		return false
+	}
+	if q.LogFilter != nil && !q.LogFilter(r) {
+		return false
+	}
+	return true
+}
+
+func (q Query) matchStat(r StatRecord) bool {
+	if !q.matchTime(r.Time) {
+		return false
+	}
+	if q.User != "" && !strings.EqualFold(statNick(r.User), q.User) {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(r.Command), strings.ToLower(q.Text)) {
+		return false
+	}
+	return true
+}
+
+// Store persists routing logs, command stats and the MOTD
+type Store interface {
+	// AppendLog records a routing log entry
+	AppendLog(r LogRecord) error
+	// Logs returns matching log records, newest first
+	Logs(q Query) ([]LogRecord, error)
+	// AppendStat records a command audit entry
+	AppendStat(r StatRecord) error
+	// Stats returns matching stat records, newest first
+	Stats(q Query) ([]StatRecord, error)
+	// MOTD returns the current message of the day
+	MOTD() (*MOTD, error)
+	// SetMOTD replaces the message of the day
+	SetMOTD(m *MOTD) error
+	// Close releases the backend
+	Close() error
+}
+
+// Open opens the named storage backend in dataDir
+func Open(backend, dataDir string) (Store, error) {
+	switch backend {
+	case "", BackendFiles:
+		return OpenFiles(dataDir)
+	case BackendBolt:
+		return OpenBolt(dataDir)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// Import copies the text file logs (logs.txt and every logs-<name>.txt),
+// stats and MOTD from dataDir into dst. dst must hold no logs or stats yet,
+// so running it twice can't duplicate records. Returns the number of log
+// and stat records imported.
+func Import(dst Store, dataDir string) (int, int, error) {
+	categories, err := logCategories(dataDir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list logs: %w", err)
+	}
+	if err := checkEmpty(dst, categories); err != nil {
+		return 0, 0, err
+	}
+
+	imported := 0
+	for _, category := range categories {
+		logs, err := loadLogFile(dataDir, category)
+		if err != nil {
+			return imported, 0, fmt.Errorf("failed to read %s: %w", logFile(dataDir, category), err)
+		}
+		// Oldest first so records keep their order in the destination
+		for _, line := range reverse(logs) {
+			r := parseLogLine(line)
+			r.Category = category
+			if err := dst.AppendLog(r); err != nil {
+				return imported, 0, fmt.Errorf("failed to import log: %w", err)
+			}
+			imported++
+		}
+	}
+
+	stats, err := LoadStats(dataDir)
+	if err != nil {
+		return imported, 0, fmt.Errorf("failed to read stats: %w", err)
+	}
+	for i, line := range stats {
+		if err := dst.AppendStat(parseStatLine(line)); err != nil {
+			return imported, i, fmt.Errorf("failed to import stat: %w", err)
+		}
+	}
+
+	motd, err := LoadMOTD(dataDir)
+	if err != nil {
+		return imported, len(stats), fmt.Errorf("failed to read MOTD: %w", err)
+	}
+	if motd.Message != "" || motd.Setter != "" {
+		if err := dst.SetMOTD(motd); err != nil {
+			return imported, len(stats), fmt.Errorf("failed to import MOTD: %w", err)
+		}
+	}
+
+	return imported, len(stats), nil
+}
+
+// checkEmpty returns an error if dst already has stats or logs in any of
+// the categories
+func checkEmpty(dst Store, categories []string) error {
+	for _, category := range categories {
+		logs, err := dst.Logs(Query{Category: category, Limit: 1})
+		if err != nil {
+			return fmt.Errorf("failed to read logs: %w", err)
+		}
+		if len(logs) > 0 {
+			return fmt.Errorf("the destination already has logs, import only into an empty database")
+		}
+	}
+	stats, err := dst.Stats(Query{Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to read stats: %w", err)
+	}
+	if len(stats) > 0 {
+		return fmt.Errorf("the destination already has stats, import only into an empty database")
+	}
+	return nil
+}
+
+// categoryPattern matches the log category names that can be stored
+var categoryPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// ValidCategory reports whether name can be used as a log category. The
+// files backend keeps each category in logs-<name>.txt.
+func ValidCategory(name string) bool {
+	return categoryPattern.MatchString(name)
+}
+
+var (
+	logLinePattern  = regexp.MustCompile(`^\[([^\]]+)\] \[([^\]]*)\]: (.*)$`)
+	statLinePattern = regexp.MustCompile(`^(.+? GMT): (.*?) -> (.*)$`)
+)
+
+// parseLogLine parses a logs.txt line. Lines in an unknown format are kept
+// whole as the text with a zero time.
+func parseLogLine(line string) LogRecord {
+	m := logLinePattern.FindStringSubmatch(line)
+	if m == nil {
+		return LogRecord{Text: line}
+	}
+	t, _ := time.Parse(logTimeFormat, m[1])
+	return LogRecord{Time: t, Server: m[2], Text: m[3]}
+}
+
+// parseStatLine parses a stats.txt line. Lines in an unknown format are
+// kept whole as the command with a zero time.
+func parseStatLine(line string) StatRecord {
+	m := statLinePattern.FindStringSubmatch(line)
+	if m == nil {
+		return StatRecord{Command: line}
+	}
+	t, _ := time.Parse(statTimeFormat, m[1])
+	return StatRecord{Time: t, User: m[2], Command: m[3]}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestImport(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rnexus-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Text files as the bot has always written them
+	SaveLogs(tmpDir, []string{
+		"[Thu Feb 20, 2025 12:00:00 GMT] [server1]: Connected",
+		"[Thu Feb 20, 2025 11:00:00 GMT] [server2]: Disconnected",
+	})
+	saveLogFile(tmpDir, "notice", []string{
+		"[Thu Feb 20, 2025 12:30:00 GMT] [server1]: *** Notice -- Client connecting",
+	})
+	SaveStats(tmpDir, []string{
+		"Thu Feb 20, 2025 at 10:00:00 GMT: alice!a@host -> !links",
+	})
+	SaveMOTD(tmpDir, &MOTD{Setter: "alice", Message: "hello"})
+
+	store, err := OpenBolt(tmpDir)
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+	defer store.Close()
+
+	logs, stats, err := Import(store, tmpDir)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if logs != 3 || stats != 1 {
+		t.Errorf("Expected 3 logs and 1 stat, got %d and %d", logs, stats)
+	}
+
+	imported, _ := store.Logs(Query{})
+	if len(imported) != 2 || imported[0].Server != "server1" {
+		t.Fatalf("Unexpected imported logs: %+v", imported)
+	}
+	if !imported[0].Time.Equal(time.Date(2025, 2, 20, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected time: %v", imported[0].Time)
+	}
+	if imported[0].String() != "[Thu Feb 20, 2025 12:00:00 GMT] [server1]: Connected" {
+		t.Errorf("Log line did not round trip: %q", imported[0].String())
+	}
+
+	importedStats, _ := store.Stats(Query{})
+	if len(importedStats) != 1 || importedStats[0].User != "alice!a@host" || importedStats[0].Command != "!links" {
+		t.Errorf("Unexpected imported stats: %+v", importedStats)
+	}
+
+	notices, _ := store.Logs(Query{Category: "notice"})
+	if len(notices) != 1 || notices[0].Text != "*** Notice -- Client connecting" {
+		t.Errorf("Unexpected imported notice log: %+v", notices)
+	}
+
+	motd, _ := store.MOTD()
+	if motd.Message != "hello" {
+		t.Errorf("Unexpected MOTD: %+v", motd)
+	}
+
+	// A second import must not duplicate anything
+	if _, _, err := Import(store, tmpDir); err == nil {
+		t.Error("Expected importing into a database with records to fail")
+	}
+	if again, _ := store.Logs(Query{}); len(again) != 2 {
+		t.Errorf("Expected the logs to be left alone, got %d", len(again))
+	}
+}
+
+func TestFileStoreQuery(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rnexus-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("OpenFiles failed: %v", err)
+	}
+
+	base := time.Date(2025, 2, 20, 12, 0, 0, 0, time.UTC)
+	store.AppendLog(LogRecord{Time: base, Server: "hub1", Text: "first"})
+	store.AppendLog(LogRecord{Time: base.Add(time.Minute), Server: "hub2", Text: "second"})
+
+	logs, _ := store.Logs(Query{Server: "HUB2"})
+	if len(logs) != 1 || logs[0].Text != "second" {
+		t.Errorf("Unexpected logs: %+v", logs)
+	}
+
+	// The file format is unchanged
+	loaded, _ := LoadLogs(tmpDir)
+	if len(loaded) != 2 || loaded[0] != "[Thu Feb 20, 2025 12:01:00 GMT] [hub2]: second" {
+		t.Errorf("Unexpected logs.txt contents: %v", loaded)
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	for _, backend := range []string{BackendFiles, BackendBolt} {
+		t.Run(backend, func(t *testing.T) {
+			store, err := Open(backend, t.TempDir())
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer store.Close()
+
+			base := time.Date(2025, 2, 20, 12, 0, 0, 0, time.UTC)
+			for i, text := range []string{"keep 1", "drop", "keep 2", "keep 3"} {
+				store.AppendLog(LogRecord{Time: base.Add(time.Duration(i) * time.Minute), Server: "hub1", Text: text})
+			}
+
+			// The filter is applied before the limit, newest first
+			logs, _ := store.Logs(Query{Limit: 2, LogFilter: func(r LogRecord) bool {
+				return r.Text != "keep 3"
+			}})
+			if len(logs) != 2 || logs[0].Text != "keep 2" || logs[1].Text != "drop" {
+				t.Errorf("Unexpected logs: %+v", logs)
+			}
+		})
+	}
+}
+
+func TestLogCategories(t *testing.T) {
+	for _, backend := range []string{BackendFiles, BackendBolt} {
+		t.Run(backend, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			store, err := Open(backend, tmpDir)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer store.Close()
+
+			base := time.Date(2025, 2, 20, 12, 0, 0, 0, time.UTC)
+			store.AppendLog(LogRecord{Time: base, Server: "hub1", Text: "Lost link"})
+			store.AppendLog(LogRecord{Time: base, Server: "hub1", Text: "K-line added", Category: "notice"})
+
+			routingLogs, _ := store.Logs(Query{})
+			if len(routingLogs) != 1 || routingLogs[0].Text != "Lost link" {
+				t.Errorf("Routing log should only hold routing notices: %+v", routingLogs)
+			}
+			notices, _ := store.Logs(Query{Category: "notice"})
+			if len(notices) != 1 || notices[0].Text != "K-line added" || notices[0].Category != "notice" {
+				t.Errorf("Unexpected notice log: %+v", notices)
+			}
+		})
+	}
+
+	store, _ := OpenFiles(t.TempDir())
+	if err := store.AppendLog(LogRecord{Text: "x", Category: "../evil"}); err == nil {
+		t.Errorf("Expected an error for an invalid category")
+	}
+}
@@ -0,0 +1,226 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/routing"
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+// Log query limits for /api/v1/logs
+const (
+	defaultLogLimit = 100
+	maxLogLimit     = 1000
+)
+
+// apiLinks is the /api/v1/links response
+type apiLinks struct {
+	Time    time.Time           `json:"time"` // When the snapshot was taken
+	Root    string              `json:"root"`
+	Servers []routing.LinkEntry `json:"servers"`
+}
+
+// apiMap is the /api/v1/map response
+type apiMap struct {
+	Servers  []*routing.Server `json:"servers"` // Effective entries, temporary assignments included
+	Sections []apiSection      `json:"sections"`
+}
+
+// apiSection is a routing map section with the names of its servers
+type apiSection struct {
+	Title   string   `json:"title"`
+	Servers []string `json:"servers"`
+}
+
+// apiMissing is the /api/v1/missing response
+type apiMissing struct {
+	Time    time.Time `json:"time"`
+	Total   int       `json:"total"`
+	Linked  int       `json:"linked"`
+	Missing []string  `json:"missing"`
+}
+
+// apiLogs is the /api/v1/logs response
+type apiLogs struct {
+	Logs []storage.LogRecord `json:"logs"` // Newest first
+}
+
+// apiMOTD is the /api/v1/motd response
+type apiMOTD struct {
+	Message string `json:"message"`
+	Setter  string `json:"setter"`
+}
+
+// errNoLinks is the 503 reply of the endpoints that need a LINKS snapshot,
+// before the first one is collected
+const errNoLinks = "no LINKS snapshot yet"
+
+// apiRoutes adds the JSON API to mux
+func (s *Server) apiRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/links", getOnly(s.apiLinks))
+	mux.HandleFunc("/api/v1/map", getOnly(s.apiMap))
+	mux.HandleFunc("/api/v1/missing", getOnly(s.apiMissing))
+	mux.HandleFunc("/api/v1/logs", getOnly(s.apiLogs))
+	mux.HandleFunc("/api/v1/motd", getOnly(s.apiMOTD))
+}
+
+func (s *Server) apiLinks(w http.ResponseWriter, r *http.Request) {
+	tree, at := s.src.LastLinks()
+	if tree == nil {
+		writeError(w, http.StatusServiceUnavailable, errNoLinks)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiLinks{Time: at.UTC(), Root: tree.Root(), Servers: tree.Entries()})
+}
+
+func (s *Server) apiMap(w http.ResponseWriter, r *http.Request) {
+	rmap := s.src.RoutingMap()
+	resp := apiMap{Servers: []*routing.Server{}, Sections: []apiSection{}}
+	for _, name := range rmap.ServerList {
+		if entry := rmap.Entry(name); entry != nil {
+			resp.Servers = append(resp.Servers, entry)
+		}
+	}
+	for _, section := range rmap.Sections {
+		names := []string{}
+		for _, entry := range section.Servers {
+			names = append(names, entry.Name)
+		}
+		resp.Sections = append(resp.Sections, apiSection{Title: section.Title, Servers: names})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) apiMissing(w http.ResponseWriter, r *http.Request) {
+	tree, at := s.src.LastLinks()
+	if tree == nil {
+		writeError(w, http.StatusServiceUnavailable, errNoLinks)
+		return
+	}
+	resp := apiMissing{Time: at.UTC(), Missing: []string{}}
+	var missing []string
+	resp.Total, resp.Linked, missing = routing.CompareToMap(tree, s.src.RoutingMap())
+	resp.Missing = append(resp.Missing, missing...)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// apiLogs returns routing notices, newest first. Parameters: since and until
+// (RFC 3339 or 2006-01-02), server (a server the notice is about or came
+// from, as in !logs server:), q (text to search for), log (a notice filter
+// other than routing) and limit.
+func (s *Server) apiLogs(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := storage.Query{
+		Text:     params.Get("q"),
+		Category: strings.ToLower(params.Get("log")),
+		Limit:    defaultLogLimit,
+	}
+	if q.Category == "routing" {
+		q.Category = ""
+	} else if q.Category != "" && !s.hasNoticeLog(q.Category) {
+		writeError(w, http.StatusBadRequest, "log: unknown name, expected one of: "+strings.Join(s.src.NoticeLogs(), ", "))
+		return
+	}
+
+	var err error
+	if q.Since, err = parseAPITime(params.Get("since")); err != nil {
+		writeError(w, http.StatusBadRequest, "since: "+err.Error())
+		return
+	}
+	if q.Until, err = parseAPITime(params.Get("until")); err != nil {
+		writeError(w, http.StatusBadRequest, "until: "+err.Error())
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxLogLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLogLimit))
+			return
+		}
+		q.Limit = n
+	}
+	if server := params.Get("server"); server != "" {
+		q.LogFilter = func(record storage.LogRecord) bool {
+			return routing.ParseNotice(record.Server, record.Text, record.Time).Involves(server)
+		}
+	}
+
+	logs, err := s.src.Store().Logs(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read logs: "+err.Error())
+		return
+	}
+	if logs == nil {
+		logs = []storage.LogRecord{}
+	}
+	writeJSON(w, http.StatusOK, apiLogs{Logs: logs})
+}
+
+// hasNoticeLog reports whether name is a configured notice filter
+func (s *Server) hasNoticeLog(name string) bool {
+	for _, known := range s.src.NoticeLogs() {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) apiMOTD(w http.ResponseWriter, r *http.Request) {
+	motd, err := s.src.Store().MOTD()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read MOTD: "+err.Error())
+		return
+	}
+	var resp apiMOTD
+	if motd != nil {
+		resp = apiMOTD{Message: motd.Message, Setter: motd.Setter}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseAPITime reads an RFC 3339 time or a date, "" gives the zero time
+func parseAPITime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or 2006-01-02", s)
+}
+
+// getOnly rejects anything but GET and HEAD
+func getOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("API: failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
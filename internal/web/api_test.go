@@ -0,0 +1,79 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dalnet/rnexus/internal/config"
+)
+
+func TestAPI(t *testing.T) {
+	h := New(&config.Config{}, newFakeSource(t)).Handler()
+
+	code, body := get(t, h, "/api/v1/links", nil)
+	var links apiLinks
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &links) != nil {
+		t.Fatalf("Bad /links response %d: %s", code, body)
+	}
+	if links.Root != "hub1.dal.net" || len(links.Servers) != 3 || links.Servers[2].Hub != "hub1.dal.net" {
+		t.Errorf("Unexpected links: %+v", links)
+	}
+
+	var missing apiMissing
+	_, body = get(t, h, "/api/v1/missing", nil)
+	if json.Unmarshal([]byte(body), &missing) != nil || len(missing.Missing) != 1 || missing.Missing[0] != "leaf3" {
+		t.Errorf("Unexpected missing servers: %s", body)
+	}
+
+	var logs apiLogs
+	_, body = get(t, h, "/api/v1/logs?server=HUB1.dal.net&q=lost", nil)
+	if json.Unmarshal([]byte(body), &logs) != nil || len(logs.Logs) != 1 {
+		t.Errorf("Expected one log record: %s", body)
+	}
+	_, body = get(t, h, "/api/v1/logs?server=leaf3", nil)
+	if json.Unmarshal([]byte(body), &logs) != nil || len(logs.Logs) != 1 {
+		t.Errorf("Expected the record about leaf3: %s", body)
+	}
+	_, body = get(t, h, "/api/v1/logs?q=nothing+like+this", nil)
+	if json.Unmarshal([]byte(body), &logs) != nil || logs.Logs == nil || len(logs.Logs) != 0 {
+		t.Errorf("Expected an empty list: %s", body)
+	}
+	if code, _ := get(t, h, "/api/v1/logs?since=yesterday", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad time, got %d", code)
+	}
+	if code, _ := get(t, h, "/api/v1/logs?log=../etc", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad log name, got %d", code)
+	}
+	if code, _ := get(t, h, "/api/v1/logs?log=nosuchlog", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown log, got %d", code)
+	}
+	_, body = get(t, h, "/api/v1/logs?log=notice", nil)
+	if json.Unmarshal([]byte(body), &logs) != nil || logs.Logs == nil || len(logs.Logs) != 0 {
+		t.Errorf("Expected an empty notice log: %s", body)
+	}
+
+	var motd apiMOTD
+	_, body = get(t, h, "/api/v1/motd", nil)
+	if json.Unmarshal([]byte(body), &motd) != nil || motd.Setter != "alice" {
+		t.Errorf("Unexpected MOTD: %s", body)
+	}
+
+	if code, _ := get(t, h, "/api/v1/map", nil); code != http.StatusOK {
+		t.Errorf("Expected 200 for /map, got %d", code)
+	}
+}
+
+func TestAPIBeforeFirstLinks(t *testing.T) {
+	src := newFakeSource(t)
+	src.tree = nil
+	h := New(&config.Config{}, src).Handler()
+
+	for _, path := range []string{"/api/v1/links", "/api/v1/missing"} {
+		code, body := get(t, h, path, nil)
+		if code != http.StatusServiceUnavailable || !strings.Contains(body, errNoLinks) {
+			t.Errorf("Expected 503 for %s before the first LINKS, got %d: %s", path, code, body)
+		}
+	}
+}
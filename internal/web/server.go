@@ -0,0 +1,90 @@
+// Package web serves a read-only view of what the bot knows over HTTP: a
+// status page, a JSON API under /api/v1 and Prometheus metrics on /metrics
+package web
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/config"
+	"github.com/dalnet/rnexus/internal/metrics"
+	"github.com/dalnet/rnexus/internal/routing"
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+// Source is the bot state the web server reports on
+type Source interface {
+	// LastLinks returns the latest LINKS snapshot, nil if there is none yet
+	LastLinks() (*routing.LinkTree, time.Time)
+	RoutingMap() *routing.Map
+	Store() storage.Store
+	Metrics() *metrics.Registry
+	// NoticeLogs returns the names of the configured notice filters
+	NoticeLogs() []string
+}
+
+// Server is the HTTP server for the status page and API
+type Server struct {
+	cfg *config.Config
+	src Source
+	srv *http.Server
+}
+
+// New returns a server for src listening on cfg.HTTPAddr()
+func New(cfg *config.Config, src Source) *Server {
+	s := &Server{cfg: cfg, src: src}
+	s.srv = &http.Server{
+		Addr:              cfg.HTTPAddr(),
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Handler returns the routes, behind basic auth when a password is set
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleStatus)
+	s.apiRoutes(mux)
+	mux.HandleFunc("/metrics", getOnly(s.handleMetrics))
+	return s.basicAuth(mux)
+}
+
+// ListenAndServe serves until Close is called
+func (s *Server) ListenAndServe() error {
+	log.Printf("Serving the status page and API on http://%s/", s.srv.Addr)
+	return s.srv.ListenAndServe()
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// basicAuth requires http_user and http_password, if a password is set
+func (s *Server) basicAuth(next http.Handler) http.Handler {
+	if s.cfg.HTTPPassword == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(s.cfg.HTTPUser)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(s.cfg.HTTPPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="rnexus"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleMetrics serves the metrics in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := s.src.Metrics().WriteText(w); err != nil {
+		log.Printf("Metrics: failed to write response: %v", err)
+	}
+}
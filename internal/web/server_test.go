@@ -0,0 +1,113 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/config"
+	"github.com/dalnet/rnexus/internal/metrics"
+	"github.com/dalnet/rnexus/internal/routing"
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+// fakeSource serves fixed bot state
+type fakeSource struct {
+	tree     *routing.LinkTree
+	rmap     *routing.Map
+	store    storage.Store
+	registry *metrics.Registry
+}
+
+func (f *fakeSource) LastLinks() (*routing.LinkTree, time.Time) { return f.tree, time.Now() }
+func (f *fakeSource) RoutingMap() *routing.Map                  { return f.rmap }
+func (f *fakeSource) Store() storage.Store                      { return f.store }
+func (f *fakeSource) Metrics() *metrics.Registry                { return f.registry }
+func (f *fakeSource) NoticeLogs() []string                      { return []string{"routing", "notice"} }
+
+func newFakeSource(t *testing.T) *fakeSource {
+	dir := t.TempDir()
+	store, err := storage.OpenFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.AppendLog(storage.LogRecord{Time: time.Now(), Server: "hub1.dal.net", Text: "Lost server connection to leaf3.dal.net: <Connection reset>"})
+	store.SetMOTD(&storage.MOTD{Setter: "alice", Message: "Hub maintenance tonight"})
+
+	tree := routing.NewLinkTree()
+	tree.Add("hub1.dal.net", "hub1.dal.net", 0, "Hub 1")
+	tree.Add("hub2.dal.net", "hub1.dal.net", 1, "Hub 2")
+	tree.Add("leaf1.dal.net", "hub1.dal.net", 1, "Leaf 1")
+
+	rmap := &routing.Map{
+		Servers: map[string][]string{
+			"hub1":  {"hub2"},
+			"leaf1": {"hub2", "hub1"},
+			"leaf3": {"hub1"},
+		},
+		ServerList: []string{"hub1", "leaf1", "leaf3"},
+	}
+	registry := metrics.NewRegistry()
+	registry.Gauge("rnexus_servers_missing", "Servers missing").Set(1)
+
+	return &fakeSource{tree: tree, rmap: rmap, store: store, registry: registry}
+}
+
+func get(t *testing.T, h http.Handler, path string, setup func(*http.Request)) (int, string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if setup != nil {
+		setup(req)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Result().Body)
+	return rec.Code, string(body)
+}
+
+func TestStatusPage(t *testing.T) {
+	s := New(&config.Config{}, newFakeSource(t))
+
+	code, body := get(t, s.Handler(), "/", nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	for _, want := range []string{
+		"Hub maintenance tonight",
+		"hub1.dal.net",
+		"<li>leaf3</li>",
+		"leaf1</td><td>hub1</td><td>secondary",
+		"Lost server connection to leaf3.dal.net: &lt;Connection reset&gt;",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Status page missing %q", want)
+		}
+	}
+
+	if code, _ := get(t, s.Handler(), "/nope", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", code)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	s := New(&config.Config{HTTPUser: "routing", HTTPPassword: "secret"}, newFakeSource(t))
+
+	if code, _ := get(t, s.Handler(), "/", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credentials, got %d", code)
+	}
+	if code, _ := get(t, s.Handler(), "/", func(r *http.Request) { r.SetBasicAuth("routing", "wrong") }); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong password, got %d", code)
+	}
+	if code, _ := get(t, s.Handler(), "/", func(r *http.Request) { r.SetBasicAuth("routing", "secret") }); code != http.StatusOK {
+		t.Errorf("Expected 200 with credentials, got %d", code)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	code, body := get(t, New(&config.Config{}, newFakeSource(t)).Handler(), "/metrics", nil)
+	if code != http.StatusOK || !strings.Contains(body, "rnexus_servers_missing 1\n") {
+		t.Errorf("Unexpected /metrics response %d:\n%s", code, body)
+	}
+}
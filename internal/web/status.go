@@ -0,0 +1,122 @@
+package web
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/routing"
+	"github.com/dalnet/rnexus/internal/storage"
+)
+
+// recentLogs is how many routing notices the status page shows
+const recentLogs = 25
+
+// statusPage is the data for statusTemplate
+type statusPage struct {
+	Now       time.Time
+	LinksAt   time.Time // Zero if there is no snapshot yet
+	Root      string
+	Tree      []string
+	Total     int
+	Linked    int
+	Missing   []string
+	Counts    map[string]int            // By placement
+	OffMap    []routing.ComplianceEntry // Servers not on their primary hub
+	Logs      []storage.LogRecord
+	MOTD      *storage.MOTD
+	LogsError string
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	page := statusPage{Now: time.Now().UTC(), Counts: make(map[string]int)}
+
+	tree, at := s.src.LastLinks()
+	rmap := s.src.RoutingMap()
+	if tree != nil && tree.Len() > 0 {
+		page.LinksAt = at.UTC()
+		page.Root = tree.Root()
+		page.Tree = tree.Build()
+		page.Total, page.Linked, page.Missing = routing.CompareToMap(tree, rmap)
+
+		report := routing.CheckCompliance(tree, rmap)
+		for _, p := range []routing.Placement{routing.OnPrimary, routing.OnSecondary, routing.OnTertiary, routing.Misrouted, routing.Unmapped} {
+			page.Counts[string(p)] = report.Count(p)
+		}
+		for _, e := range report.Entries {
+			if e.Placement != routing.OnPrimary {
+				page.OffMap = append(page.OffMap, e)
+			}
+		}
+	}
+
+	store := s.src.Store()
+	logs, err := store.Logs(storage.Query{Limit: recentLogs})
+	if err != nil {
+		page.LogsError = err.Error()
+	}
+	page.Logs = logs
+	if page.MOTD, err = store.MOTD(); err != nil {
+		log.Printf("Status page: failed to read MOTD: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, page); err != nil {
+		log.Printf("Status page: %v", err)
+	}
+}
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Format("Mon Jan 02, 2006 15:04:05 GMT") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>rnexus status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f4f4f4; padding: 1em; overflow-x: auto; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+.misrouted { color: #b00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>rnexus status</h1>
+<p>Generated {{time .Now}}</p>
+
+{{with .MOTD}}{{if .Message}}<h2>MOTD</h2>
+<p>{{.Message}}<br><small>Set by {{.Setter}}</small></p>{{end}}{{end}}
+
+<h2>Links</h2>
+{{if .LinksAt.IsZero}}<p>No LINKS snapshot yet.</p>{{else}}
+<p>As seen from {{.Root}} at {{time .LinksAt}}. {{.Linked}} of {{.Total}} servers in the routing map are linked.</p>
+<pre>{{range .Tree}}{{.}}
+{{end}}</pre>
+
+<h2>Missing servers</h2>
+{{if .Missing}}<ul>{{range .Missing}}<li>{{.}}</li>{{end}}</ul>{{else}}<p>No servers are currently missing.</p>{{end}}
+
+<h2>Compliance</h2>
+<p>{{index .Counts "primary"}} on primary, {{index .Counts "secondary"}} on secondary, {{index .Counts "tertiary"}} on tertiary,
+<span class="misrouted">{{index .Counts "misrouted"}} misrouted</span>, {{index .Counts "unmapped"}} not in the map.</p>
+{{if .OffMap}}<table>
+<tr><th>Server</th><th>Linked to</th><th>Placement</th><th>Map hubs</th></tr>
+{{range .OffMap}}<tr{{if eq .Placement "misrouted"}} class="misrouted"{{end}}><td>{{.Server}}</td><td>{{.Hub}}</td><td>{{.Placement}}</td><td>{{range $i, $h := .Expected}}{{if $i}} {{end}}{{$h}}{{end}}</td></tr>
+{{end}}</table>{{end}}
+{{end}}
+
+<h2>Recent routing notices</h2>
+{{if .LogsError}}<p>Error reading logs: {{.LogsError}}</p>{{end}}
+{{if .Logs}}<pre>{{range .Logs}}{{.}}
+{{end}}</pre>{{else}}<p>No routing notices logged.</p>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,334 @@
+// Package webhook POSTs routing events to configured URLs
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/config"
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+// Payload formats
+const (
+	FormatJSON    = "json"    // Payload as is
+	FormatSlack   = "slack"   // {"text": ...}
+	FormatDiscord = "discord" // {"content": ...}
+)
+
+// Delivery settings
+const (
+	queueSize      = 100
+	maxAttempts    = 5
+	requestTimeout = 10 * time.Second
+	retryMin       = time.Second
+	retryMax       = 30 * time.Second
+)
+
+// SignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>" when the
+// webhook has a secret
+const SignatureHeader = "X-Rnexus-Signature"
+
+// EventHeader carries the event type
+const EventHeader = "X-Rnexus-Event"
+
+// defaultEvents are sent when a webhook has no event filter
+var defaultEvents = []string{
+	string(routing.EventSplit),
+	string(routing.EventLinkEstablished),
+	string(routing.EventHubChanged),
+}
+
+// defaultTemplate renders the message for Slack and Discord
+const defaultTemplate = "[{{.Type}}] {{.Text}}{{if ge .Missing 0}} ({{.Missing}} servers missing){{end}}"
+
+// Payload is the JSON body sent for an event
+type Payload struct {
+	Type    string    `json:"type"`
+	Servers []string  `json:"servers"`
+	Reason  string    `json:"reason,omitempty"`
+	Text    string    `json:"text"`
+	Source  string    `json:"source"` // Server that sent the notice, or "LINKS poll"
+	Time    time.Time `json:"time"`
+	Missing int       `json:"missing"` // Servers missing from the map, -1 before the first LINKS
+}
+
+// NewPayload builds the payload for a routing event
+func NewPayload(event *routing.Event, missing int) Payload {
+	servers := event.Servers
+	if servers == nil {
+		servers = []string{}
+	}
+	return Payload{
+		Type:    string(event.Type),
+		Servers: servers,
+		Reason:  event.Reason,
+		Text:    event.Text,
+		Source:  event.Source,
+		Time:    event.Time.UTC(),
+		Missing: missing,
+	}
+}
+
+// hook is one configured webhook with its delivery queue
+type hook struct {
+	name     string // Number and host for logs; the URL may hold a token
+	url      string
+	secret   []byte
+	format   string
+	events   map[string]bool
+	template *template.Template
+	queue    chan Payload
+}
+
+// Notifier delivers payloads to every webhook that wants them. Each webhook
+// has its own queue and worker so a slow one doesn't hold up the rest.
+type Notifier struct {
+	hooks  []*hook
+	client *http.Client
+
+	// Retry delays, shortened by tests
+	retryMin time.Duration
+	retryMax time.Duration
+
+	mu     sync.Mutex // Guards closed and sends on the queues
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New checks the webhook settings and starts a worker for each one. It
+// returns nil when no webhooks are configured.
+func New(cfgs []config.Webhook) (*Notifier, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	n := &Notifier{
+		client:   &http.Client{Timeout: requestTimeout},
+		retryMin: retryMin,
+		retryMax: retryMax,
+	}
+	for i, cfg := range cfgs {
+		h, err := newHook(i+1, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %d: %w", i+1, err)
+		}
+		n.hooks = append(n.hooks, h)
+	}
+
+	for _, h := range n.hooks {
+		n.wg.Add(1)
+		go n.run(h)
+	}
+	return n, nil
+}
+
+func newHook(n int, cfg config.Webhook) (*hook, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("invalid url, expected http:// or https:// and a host")
+	}
+
+	h := &hook{
+		name:   fmt.Sprintf("%d (%s)", n, u.Host),
+		url:    cfg.URL,
+		secret: []byte(cfg.Secret),
+		format: strings.ToLower(cfg.Format),
+		events: make(map[string]bool),
+		queue:  make(chan Payload, queueSize),
+	}
+	switch h.format {
+	case "":
+		h.format = FormatJSON
+	case FormatJSON, FormatSlack, FormatDiscord:
+	default:
+		return nil, fmt.Errorf("unknown format %q (use json, slack or discord)", cfg.Format)
+	}
+
+	text := cfg.Template
+	if text == "" {
+		text = defaultTemplate
+	}
+	if h.template, err = template.New("webhook").Parse(text); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	events := cfg.Events
+	if len(events) == 0 {
+		events = defaultEvents
+	}
+	for _, name := range events {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !validEvent(name) {
+			log.Printf("Warning: unknown webhook event %q ignored", name)
+			continue
+		}
+		h.events[name] = true
+	}
+	return h, nil
+}
+
+func validEvent(name string) bool {
+	for _, t := range routing.EventTypes {
+		if name == string(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify queues p for every webhook whose filter includes its type. It never
+// blocks; a payload is dropped if a webhook's queue is full or the notifier
+// is closed.
+func (n *Notifier) Notify(p Payload) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	for _, h := range n.hooks {
+		if !h.events[p.Type] {
+			continue
+		}
+		select {
+		case h.queue <- p:
+		default:
+			log.Printf("Webhook %s: queue full, dropping %s event", h.name, p.Type)
+		}
+	}
+}
+
+// Close stops taking events and waits up to timeout for the queues to be
+// delivered. Anything still queued after that is abandoned.
+func (n *Notifier) Close(timeout time.Duration) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		for _, h := range n.hooks {
+			close(h.queue)
+		}
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Webhooks not delivered within %s, giving up", timeout)
+	}
+}
+
+func (n *Notifier) run(h *hook) {
+	defer n.wg.Done()
+	for p := range h.queue {
+		body, err := h.body(p)
+		if err != nil {
+			log.Printf("Webhook %s: %v", h.name, err)
+			continue
+		}
+		n.deliver(h, p.Type, body)
+	}
+}
+
+// deliver POSTs body, retrying network errors, 429 and 5xx responses with
+// exponential backoff
+func (n *Notifier) deliver(h *hook, event string, body []byte) {
+	delay := n.retryMin
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(h, event, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == maxAttempts {
+			log.Printf("Webhook %s: giving up on %s event after %d attempts: %v", h.name, event, attempt, err)
+			return
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > n.retryMax {
+			delay = n.retryMax
+		}
+	}
+}
+
+// post sends one request. It reports whether a failure is worth retrying.
+func (n *Notifier) post(h *hook, event string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rnexus")
+	req.Header.Set(EventHeader, event)
+	if len(h.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(h.secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// Leave out the URL, which may hold the webhook's token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("server replied %s", resp.Status)
+	default:
+		return false, fmt.Errorf("server replied %s", resp.Status)
+	}
+}
+
+// body renders the request body in the webhook's format
+func (h *hook) body(p Payload) ([]byte, error) {
+	if h.format == FormatJSON {
+		return json.Marshal(p)
+	}
+
+	var text strings.Builder
+	if err := h.template.Execute(&text, p); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	key := "text"
+	if h.format == FormatDiscord {
+		key = "content"
+	}
+	return json.Marshal(map[string]string{key: text.String()})
+}
+
+// Sign returns the hex HMAC-SHA256 of body with secret, as sent in
+// SignatureHeader
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,167 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dalnet/rnexus/internal/config"
+	"github.com/dalnet/rnexus/internal/routing"
+)
+
+// recorder is a test server that fails the first failures requests
+type recorder struct {
+	mu       sync.Mutex
+	failures int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "try later", http.StatusServiceUnavailable)
+		return
+	}
+	r.bodies = append(r.bodies, string(body))
+	r.headers = append(r.headers, req.Header.Clone())
+}
+
+func testEvent(typ routing.EventType) *routing.Event {
+	return &routing.Event{
+		Time:    time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		Source:  "hub1",
+		Type:    typ,
+		Servers: []string{"leaf1.dal.net", "hub1.dal.net"},
+		Reason:  "Ping timeout",
+		Text:    "leaf1.dal.net split from hub1.dal.net (Ping timeout)",
+	}
+}
+
+func TestDeliverSignedWithRetry(t *testing.T) {
+	rec := &recorder{failures: 2}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]config.Webhook{{URL: srv.URL, Secret: "s3cret"}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	n.retryMin, n.retryMax = time.Millisecond, 2*time.Millisecond
+
+	n.Notify(NewPayload(testEvent(routing.EventLinkStats), 3)) // Filtered out
+	n.Notify(NewPayload(testEvent(routing.EventSplit), 3))
+	n.Close(5 * time.Second)
+
+	if len(rec.bodies) != 1 {
+		t.Fatalf("Expected one delivery, got %d", len(rec.bodies))
+	}
+	var p Payload
+	if err := json.Unmarshal([]byte(rec.bodies[0]), &p); err != nil {
+		t.Fatalf("Bad payload: %v", err)
+	}
+	if p.Type != "split" || p.Missing != 3 || p.Reason != "Ping timeout" || len(p.Servers) != 2 {
+		t.Errorf("Unexpected payload: %+v", p)
+	}
+	if got, want := rec.headers[0].Get(SignatureHeader), "sha256="+Sign([]byte("s3cret"), []byte(rec.bodies[0])); got != want {
+		t.Errorf("Expected signature %s, got %s", want, got)
+	}
+	if rec.headers[0].Get(EventHeader) != "split" {
+		t.Errorf("Expected the event header to be set")
+	}
+}
+
+func TestSlackAndDiscordFormats(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]config.Webhook{
+		{URL: srv.URL, Format: "slack", Events: []string{"split"}},
+		{URL: srv.URL, Format: "discord", Events: []string{"split"}, Template: "{{.Type}}: {{index .Servers 0}}"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	n.Notify(NewPayload(testEvent(routing.EventSplit), -1))
+	n.Close(5 * time.Second)
+
+	want := map[string]bool{
+		`{"text":"[split] leaf1.dal.net split from hub1.dal.net (Ping timeout)"}`: true,
+		`{"content":"split: leaf1.dal.net"}`:                                      true,
+	}
+	if len(rec.bodies) != 2 {
+		t.Fatalf("Expected two deliveries, got %v", rec.bodies)
+	}
+	for _, body := range rec.bodies {
+		if !want[body] {
+			t.Errorf("Unexpected body %s", body)
+		}
+	}
+}
+
+func TestErrorsHideURL(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	hookURL := srv.URL + "/services/secret-token"
+	srv.Close() // Refuse connections
+
+	n, err := New([]config.Webhook{{URL: hookURL}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer n.Close(time.Second)
+
+	h := n.hooks[0]
+	if strings.Contains(h.name, "secret-token") {
+		t.Errorf("Webhook name %q holds the URL path", h.name)
+	}
+	if _, err := n.post(h, "split", []byte("{}")); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected an error without the URL, got %v", err)
+	}
+}
+
+func TestCloseIsBounded(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	n, err := New([]config.Webhook{{URL: srv.URL}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	n.Notify(NewPayload(testEvent(routing.EventSplit), 0))
+
+	start := time.Now()
+	n.Close(50 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %s", elapsed)
+	}
+	n.Notify(NewPayload(testEvent(routing.EventSplit), 0)) // Dropped, not a panic
+}
+
+func TestNewRejectsBadSettings(t *testing.T) {
+	for _, cfg := range []config.Webhook{
+		{URL: "ftp://example.com/hook"},
+		{URL: "http://example.com/hook", Format: "teams"},
+		{URL: "http://example.com/hook", Format: "slack", Template: "{{.Nope"},
+	} {
+		if _, err := New([]config.Webhook{cfg}); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+
+	if n, err := New(nil); n != nil || err != nil {
+		t.Errorf("Expected no notifier without webhooks")
+	}
+}